@@ -11,19 +11,83 @@ import (
 )
 
 func main() {
-	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
 	os.Exit(run(context.Background()))
 }
 
 func run(ctx context.Context) int {
 	cfg := initConfig()
-	srv, err := service.New(cfg.Src, cfg.Dst, cfg.Force)
+	initLogger(cfg)
+
+	if cfg.Pipe {
+		if err := service.Pipe(cfg, os.Stdin, os.Stdout); err != nil {
+			slog.Default().ErrorContext(ctx, "pipe request failed", "error", err)
+			return 1
+		}
+		return 0
+	}
+
+	if cfg.Encrypt != "" {
+		if err := service.EncryptFile(cfg.Encrypt); err != nil {
+			slog.Default().ErrorContext(ctx, "encryption failed", "error", err)
+			return 1
+		}
+		return 0
+	}
+
+	if cfg.Diff {
+		if err := service.Diff(cfg, os.Stdout); err != nil {
+			slog.Default().ErrorContext(ctx, "diff failed", "error", err)
+			return 1
+		}
+		return 0
+	}
+
+	if cfg.Matrix {
+		if err := service.Matrix(cfg, os.Stdout); err != nil {
+			slog.Default().ErrorContext(ctx, "matrix report failed", "error", err)
+			return 1
+		}
+		return 0
+	}
+
+	if dsts := service.Destinations(cfg.Dst); len(dsts) > 1 && !cfg.CheckSecrets && !cfg.Fmt && !cfg.Compact && !cfg.Dedupe && cfg.Since == "" && cfg.RedactOut == "" {
+		if err := service.RunBatch(cfg, dsts); err != nil {
+			slog.Default().ErrorContext(ctx, "batch run failed", "error", err)
+			return 1
+		}
+		return 0
+	}
+
+	if skip, err := service.SkipIfUnchanged(cfg); err != nil {
+		slog.Default().ErrorContext(ctx, "cached run failed", "error", err)
+		return 1
+	} else if skip {
+		return 0
+	}
+
+	srv, err := service.Open(cfg.Src, cfg.Dst, service.WithConfig(cfg))
 	if err != nil {
 		slog.Default().ErrorContext(ctx, "service initialization failed", "error", err)
 		return 1
 	}
 
-	if err = srv.Run(); err != nil {
+	switch {
+	case cfg.Fmt:
+		err = srv.Format()
+	case cfg.Compact:
+		err = srv.Compact()
+	case cfg.Dedupe:
+		err = srv.Dedupe()
+	case cfg.Since != "":
+		err = srv.ListSince()
+	case cfg.RedactOut != "":
+		err = srv.Redact()
+	case cfg.CheckSecrets:
+		err = srv.CheckSecrets()
+	default:
+		err = srv.Run()
+	}
+	if err != nil {
 		slog.Default().ErrorContext(ctx, "service run failed", "error", err)
 		return 1
 	}
@@ -31,15 +95,118 @@ func run(ctx context.Context) int {
 	return 0
 }
 
+func initLogger(cfg config.Config) {
+	level := slog.LevelInfo
+	if cfg.Quiet {
+		level = slog.LevelWarn
+	}
+
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+}
+
 func initConfig() config.Config {
-	force := flag.Bool("force", false, "append updates for differing keys")
-	dst := flag.String("dst", ".env", "destination .env file path")
-	src := flag.String("src", ".env.example", "source .env.example file path")
+	// Flags start from the library defaults, so the two cannot drift apart.
+	cfg := service.DefaultConfig()
+
+	flag.BoolVar(&cfg.Force, "force", cfg.Force, "append updates for differing keys")
+	flag.BoolVar(&cfg.Fmt, "fmt", cfg.Fmt, "rewrite destination into canonical form without merging")
+	flag.BoolVar(&cfg.Pipe, "pipe", cfg.Pipe, "read a JSON {src, dst, options} request from stdin and write the JSON plan and result to stdout")
+	flag.BoolVar(&cfg.Matrix, "matrix", cfg.Matrix, "report which source keys each of the comma-separated --dst files has, lacks or holds differently, without writing")
+	flag.BoolVar(&cfg.Diff, "diff", cfg.Diff, "compare the two env files given as arguments (--diff a.env b.env) without merging")
+	flag.BoolVar(&cfg.Dedupe, "dedupe", cfg.Dedupe, "rewrite the destination keeping only the last definition of each key, without merging")
+	flag.BoolVar(&cfg.Compact, "compact", cfg.Compact, "collapse the destination's sync blocks into one without merging")
+	flag.BoolVar(&cfg.RespectManaged, "respect-managed", cfg.RespectManaged, "only force-update destination keys with a \"# envmerge:managed\" comment above them")
+	flag.BoolVar(&cfg.NormalizeBools, "normalize-bools", cfg.NormalizeBools, "write boolean-ish values (yes/no, on/off, 1/0) as true or false")
+	flag.BoolVar(&cfg.AddOnly, "add-only", cfg.AddOnly, "only add missing keys, never update existing values; fails if combined with --force or --force-keys")
+	flag.BoolVar(&cfg.ValueCaseInsensitive, "value-case-insensitive", cfg.ValueCaseInsensitive, "in force mode, treat values that differ only by case (true/TRUE) as equal")
+	flag.StringVar(&cfg.RedactOut, "redact-out", cfg.RedactOut, "write a copy of the destination with --secret-pattern values masked to this file, without merging")
+	flag.StringVar(&cfg.Since, "since", cfg.Since, "list keys synced at or after this time (YYYY-MM-DD or YYYY-MM-DD HH:MM:SS) without merging")
+	flag.IntVar(&cfg.MaxChanges, "max-changes", cfg.MaxChanges, "fail without writing when a force run would change more keys than this; 0 disables")
+	flag.BoolVar(&cfg.ConfirmLarge, "confirm-large", cfg.ConfirmLarge, "allow a force run to exceed --max-changes")
+	flag.StringVar(&cfg.ForceKeys, "force-keys", cfg.ForceKeys, "file with newline-delimited keys to force-update")
+	flag.StringVar(&cfg.Dst, "dst", cfg.Dst, "destination .env file path, or a comma-separated list to sync each of them")
+	flag.StringVar(&cfg.Src, "src", cfg.Src, "source .env.example file path or git:REF:PATH, or a comma-separated list of layers, lowest precedence first")
+	flag.BoolVar(&cfg.DetectConflicts, "detect-conflicts", cfg.DetectConflicts, "warn about keys that --src layers define with different values")
+	flag.BoolVar(&cfg.FailOnConflict, "fail-on-conflict", cfg.FailOnConflict, "like --detect-conflicts, but fail without writing when layers disagree")
+	flag.StringVar(&cfg.Overlay, "overlay", cfg.Overlay, "environment overlay whose values take precedence over --src")
+	flag.BoolVar(&cfg.Interpolate, "interpolate", cfg.Interpolate, "expand ${VAR}, ${VAR:-default} and ${VAR:+alt} in source values from earlier source keys, then the environment")
+	flag.BoolVar(&cfg.InterpolateStrict, "interpolate-strict", cfg.InterpolateStrict, "with --interpolate, fail on unset variables and unsupported ${...} forms instead of leaving them")
+	flag.BoolVar(&cfg.ExpandFileRefs, "expand-file-refs", cfg.ExpandFileRefs, "read unquoted source values like @path/to/file from that file, relative to the source")
+	flag.StringVar(&cfg.DstFormat, "dst-format", cfg.DstFormat, "destination format: env, or json to merge into a JSON file")
+	flag.StringVar(&cfg.DstTemplate, "dst-template", cfg.DstTemplate, "layout file for a new or empty destination: its comments and key order are kept with merged values filled in")
+	flag.StringVar(&cfg.Owner, "owner", cfg.Owner, "user:group (names or ids) to chown the destination or --out file to when envmerge creates it")
+	flag.BoolVar(&cfg.TargetExistingOnly, "target-existing-only", cfg.TargetExistingOnly, "refuse to add keys to a destination that has none yet, unless --init is set")
+	flag.BoolVar(&cfg.Init, "init", cfg.Init, "with --target-existing-only, allow populating an empty or missing destination")
+	flag.BoolVar(&cfg.EnsureGitignore, "ensure-gitignore", cfg.EnsureGitignore, "add the destination to the .gitignore in its directory if it is not listed yet")
+	flag.StringVar(&cfg.Out, "out", cfg.Out, "write the merged result to this file instead of --dst")
+	flag.IntVar(&cfg.MaxLineSize, "max-line-size", cfg.MaxLineSize, "maximum size in bytes of a single line")
+	flag.Func("rename", "OLD=NEW: treat a destination OLD as NEW and carry its value (repeatable)", func(v string) error {
+		cfg.Renames = append(cfg.Renames, v)
+		return nil
+	})
+	flag.StringVar(&cfg.MapFile, "map-file", cfg.MapFile, "file of source value replacements: KEY=OLD -> NEW, KEY -> NEW or *=OLD -> NEW")
+	flag.StringVar(&cfg.RenameFile, "rename-file", cfg.RenameFile, "file with newline-delimited OLD=NEW renames")
+	flag.BoolVar(&cfg.RemoveRenamed, "remove-renamed", cfg.RemoveRenamed, "remove the old key of a rename from the destination once the new key is written")
+	flag.StringVar(&cfg.KeysFile, "keys-file", cfg.KeysFile, "file with newline-delimited keys to sync; other source keys are ignored")
+	flag.StringVar(&cfg.Exclude, "exclude", cfg.Exclude, "comma-separated key globs to skip; a leading ! re-includes")
+	flag.BoolVar(&cfg.GroupByPrefix, "group-by-prefix", cfg.GroupByPrefix, "group a sync block's keys by their first underscore segment, separated by blank lines")
+	flag.BoolVar(&cfg.Summary, "summary", cfg.Summary, "append a one-line change summary comment to each sync block")
+	flag.BoolVar(&cfg.WarnSimilar, "warn-similar", cfg.WarnSimilar, "warn about keys that differ only by case or surrounding underscores")
+	flag.BoolVar(&cfg.ValidateTypes, "validate-types", cfg.ValidateTypes, "strip \"# type:NAME\" hints from source values and fail if merged values don't match them")
+	flag.BoolVar(&cfg.FailOnDeprecated, "fail-on-deprecated", cfg.FailOnDeprecated, "fail without writing while the destination has keys the source marks '# envmerge:deprecated'")
+	flag.BoolVar(&cfg.FailOnDestOnly, "fail-on-dest-only", cfg.FailOnDestOnly, "fail without writing when the destination has keys missing from the source")
+	flag.BoolVar(&cfg.ManagedRegion, "managed-region", cfg.ManagedRegion, "only read and write the destination between the envmerge managed markers, adding them if absent")
+	flag.StringVar(&cfg.LineEnding, "line-ending", cfg.LineEnding, "line ending for written lines: lf, crlf or auto (match destination)")
+	flag.BoolVar(&cfg.Interactive, "interactive", cfg.Interactive, "ask about every add or update on the terminal before writing")
+	flag.StringVar(&cfg.InteractiveFallback, "interactive-fallback", cfg.InteractiveFallback, "with --interactive and no terminal on stdin: none applies nothing, all applies every change")
+	flag.BoolVar(&cfg.DryRun, "dry-run", cfg.DryRun, "print the plan instead of writing")
+	flag.StringVar(&cfg.Patch, "patch", cfg.Patch, "write the change as a unified diff against the destination to this file (- for stdout) instead of writing")
+	flag.BoolVar(&cfg.Checksum, "checksum", cfg.Checksum, "print a SHA-256 of the merged environment's sorted key/value pairs instead of writing")
+	flag.BoolVar(&cfg.PrintEffective, "print-effective", cfg.PrintEffective, "print the merged environment as sorted KEY=value lines instead of writing")
+	flag.BoolVar(&cfg.MaskSecrets, "mask-secrets", cfg.MaskSecrets, "mask --secret-pattern values in --print-effective output")
+	flag.StringVar(&cfg.Format, "format", cfg.Format, "output format for the plan: text or json; sh prints the changes as export statements instead of writing")
+	flag.IntVar(&cfg.PreviewLines, "preview-lines", cfg.PreviewLines, "print at most this many entries of a text plan or diff, then a count of the rest (0 prints all)")
+	flag.BoolVar(&cfg.CheckSecrets, "check-secrets", cfg.CheckSecrets, "report destination secrets that are empty, short, placeholders or unchanged from the source, without writing")
+	flag.IntVar(&cfg.MinSecretLength, "min-secret-length", cfg.MinSecretLength, "with --check-secrets, the shortest acceptable secret")
+	flag.StringVar(&cfg.SecretPattern, "secret-pattern", cfg.SecretPattern, "comma-separated key globs whose values are masked in output")
+	flag.StringVar(&cfg.PlaceholderPattern, "placeholder-pattern", cfg.PlaceholderPattern, "regexp of source values that never overwrite existing destination values; empty disables")
+	flag.BoolVar(&cfg.CollapseWhitespace, "collapse-whitespace", cfg.CollapseWhitespace, "collapse runs of spaces and tabs inside unquoted single-line values to one space")
+	flag.BoolVar(&cfg.ShellArrays, "shell-arrays", cfg.ShellArrays, "leave shell array values like (a b c) unquoted")
+	flag.BoolVar(&cfg.CarryComments, "carry-comments", cfg.CarryComments, "copy the comment line above each source key into the sync block")
+	flag.BoolVar(&cfg.MergeCommentsFromDest, "merge-comments-from-dest", cfg.MergeCommentsFromDest, "with --carry-comments, keep destination comments for updated keys and carry source comments for new keys only")
+	flag.StringVar(&cfg.ReportFile, "report-file", cfg.ReportFile, "write a JSON run summary to this file")
+	flag.BoolVar(&cfg.ReportAppend, "report-append", cfg.ReportAppend, "append the run summary to --report-file as a JSON line instead of overwriting")
+	flag.BoolVar(&cfg.Lock, "lock", cfg.Lock, "keep value hashes in <dst>.lock and warn before overwriting values edited since the last sync")
+	flag.StringVar(&cfg.Manifest, "manifest", cfg.Manifest, "write a JSON manifest of the source keys (required, secret, multiline, type, description) to this file on every run")
+	flag.StringVar(&cfg.AuditLog, "audit-log", cfg.AuditLog, "append one JSON line per sync run, with secret values masked, to this file")
+	flag.StringVar(&cfg.AuditActor, "audit-actor", cfg.AuditActor, "actor recorded in --audit-log (default: $ENVMERGE_ACTOR)")
+	flag.StringVar(&cfg.OutPerm, "out-perm", cfg.OutPerm, "octal mode of a --out file envmerge creates")
+	flag.BoolVar(&cfg.Backup, "backup", cfg.Backup, "copy the destination to <dst>.bak before writing it")
+	flag.StringVar(&cfg.BackupDir, "backup-dir", cfg.BackupDir, "write backups to this directory instead, named after the destination's path and the time (implies --backup)")
+	flag.BoolVar(&cfg.CheckUnmodified, "check-unmodified", cfg.CheckUnmodified, "abort without writing if the destination's size or modification time changed since it was read")
+	flag.BoolVar(&cfg.Transactional, "transactional", cfg.Transactional, "with several destinations, update all of them or none: on the first failure, restore every destination already synced")
+	flag.StringVar(&cfg.Cache, "cache", cfg.Cache, "skip the run when this cache file shows the last one found nothing to do and no input changed since")
+	flag.BoolVar(&cfg.ThreeWay, "three-way", cfg.ThreeWay, "merge against the .lock from the last sync: take source-only changes, keep destination-only changes, report keys changed on both sides")
+	flag.StringVar(&cfg.ConflictsOut, "conflicts-out", cfg.ConflictsOut, "with --three-way, write unresolved keys to this file with conflict markers")
+	flag.BoolVar(&cfg.BlankValues, "blank-values", cfg.BlankValues, "print the source keys missing from the destination as KEY= lines, without values, and write nothing")
+	flag.BoolVar(&cfg.Trace, "trace", cfg.Trace, "log, for every source key, whether it is written and why")
+	flag.BoolVar(&cfg.Quiet, "quiet", cfg.Quiet, "log warnings and errors only")
+	flag.BoolVar(&cfg.UpperKeys, "upper-keys", cfg.UpperKeys, "upper-case all keys before comparing and writing")
+	flag.BoolVar(&cfg.StripExport, "strip-export", cfg.StripExport, "never write the export prefix: drop it from copied source keys and existing destination lines")
+	flag.BoolVar(&cfg.DecodeEscapes, "decode-escapes", cfg.DecodeEscapes, "decode \\n, \\t and \\\\ in unquoted values, and encode them on write")
+	flag.BoolVar(&cfg.Lenient, "lenient", cfg.Lenient, "skip malformed lines with a warning instead of failing")
+	flag.StringVar(&cfg.Encrypt, "encrypt", cfg.Encrypt, "encrypt this file with the key in ENVMERGE_SOURCE_KEY to <file>.enc and exit")
+	flag.BoolVar(&cfg.Recover, "recover", cfg.Recover, "keep a multiline value left unterminated at the end of a file, with a warning, instead of failing")
+	flag.BoolVar(&cfg.FailOnParseWarning, "fail-on-parse-warning", cfg.FailOnParseWarning, "like --lenient, but fail without writing if any line was skipped")
+	flag.StringVar(&cfg.DstDuplicates, "dst-duplicates", cfg.DstDuplicates, "keys the destination defines more than once: last compares the source against the last definition, first against the first, warn uses the last and warns")
+	flag.BoolVar(&cfg.Meta, "meta", cfg.Meta, "parse # @meta {...} JSON comments above keys as their metadata (required, group, type, description)")
+	flag.StringVar(&cfg.KeyPolicy, "key-policy", cfg.KeyPolicy, "keys with whitespace like \"A B=1\": strict fails, first-token reads the key as A")
+	flag.StringVar(&cfg.ControlChars, "control-chars", cfg.ControlChars, "values with control characters other than tab and line breaks: reject fails, strip removes them")
+	flag.BoolVar(&cfg.ValidateKeys, "validate-keys", cfg.ValidateKeys, "reject keys that are not POSIX identifiers ([A-Za-z_][A-Za-z0-9_]*)")
+	flag.BoolVar(&cfg.AllowNumericKeys, "allow-numeric-keys", cfg.AllowNumericKeys, "with --validate-keys, also accept keys starting with a digit, like 123")
+	flag.BoolVar(&cfg.NoTrim, "no-trim", cfg.NoTrim, "preserve leading/trailing whitespace of unquoted values")
 	flag.Parse()
+	cfg.DiffFiles = flag.Args()
 
-	return config.Config{
-		Force: *force,
-		Dst:   *dst,
-		Src:   *src,
-	}
+	return cfg
 }
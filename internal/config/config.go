@@ -1,6 +1,101 @@
 package config
 
 type Config struct {
-	Force    bool
-	Dst, Src string
+	Force                 bool
+	Fmt                   bool
+	Quiet                 bool
+	Summary               bool
+	WarnSimilar           bool
+	NoTrim                bool
+	FailOnDestOnly        bool
+	DryRun                bool
+	ShellArrays           bool
+	ReportAppend          bool
+	CarryComments         bool
+	MergeCommentsFromDest bool
+	ExpandFileRefs        bool
+	RemoveRenamed         bool
+	DecodeEscapes         bool
+	StripExport           bool
+	Compact               bool
+	Pipe                  bool
+	ValueCaseInsensitive  bool
+	ValidateTypes         bool
+	PrintEffective        bool
+	MaskSecrets           bool
+	ManagedRegion         bool
+	UpperKeys             bool
+	RespectManaged        bool
+	Lock                  bool
+	CollapseWhitespace    bool
+	Diff                  bool
+	ValidateKeys          bool
+	AllowNumericKeys      bool
+	EnsureGitignore       bool
+	ConfirmLarge          bool
+	GroupByPrefix         bool
+	DetectConflicts       bool
+	FailOnConflict        bool
+	CheckSecrets          bool
+	Lenient               bool
+	FailOnParseWarning    bool
+	Matrix                bool
+	Dedupe                bool
+	TargetExistingOnly    bool
+	Init                  bool
+	NormalizeBools        bool
+	AddOnly               bool
+	FailOnDeprecated      bool
+	Checksum              bool
+	Interactive           bool
+	Trace                 bool
+	Transactional         bool
+	Interpolate           bool
+	InterpolateStrict     bool
+	ThreeWay              bool
+	BlankValues           bool
+	Meta                  bool
+	CheckUnmodified       bool
+	Backup                bool
+	Recover               bool
+
+	Dst, Src            string
+	Out                 string
+	ForceKeys           string
+	Exclude             string
+	LineEnding          string
+	Format              string
+	SecretPattern       string
+	PlaceholderPattern  string
+	Overlay             string
+	ReportFile          string
+	RenameFile          string
+	AuditLog            string
+	AuditActor          string
+	Since               string
+	DstTemplate         string
+	Patch               string
+	KeysFile            string
+	DstFormat           string
+	RedactOut           string
+	Manifest            string
+	Owner               string
+	KeyPolicy           string
+	ControlChars        string
+	InteractiveFallback string
+	OutPerm             string
+	ConflictsOut        string
+	Cache               string
+	MapFile             string
+	DstDuplicates       string
+	BackupDir           string
+	Encrypt             string
+
+	MaxLineSize     int
+	MaxChanges      int
+	MinSecretLength int
+	PreviewLines    int
+
+	Renames   []string
+	DiffFiles []string
 }
@@ -0,0 +1,68 @@
+package field
+
+import "strings"
+
+type EntryKind int
+
+const (
+	KindBlank EntryKind = iota
+	KindComment
+	KindVar
+)
+
+// Entry is a single logical line of an env file. Multiline values span
+// several physical lines; Raw keeps their exact text including line
+// terminators, so concatenating every Raw reproduces the file byte-for-byte.
+// Doc holds the run of comment lines directly above a var, if any, without a
+// leading shebang; Export is set for shell-style "export KEY=value" lines, and
+// Type holds a "# type:NAME" hint. Meta holds the object of a
+// "# @meta {...}" line in Doc when metadata parsing is on.
+type Entry struct {
+	Kind   EntryKind
+	Key    string
+	Value  string
+	Quoted bool
+	Export bool
+	Type   string
+	Raw    string
+	Line   int
+	Doc    []string
+	Meta   map[string]any
+}
+
+type Document struct {
+	Entries []Entry
+}
+
+// Map returns the key/value view of the document; the last duplicate wins.
+func (d *Document) Map() map[string]string {
+	env := make(map[string]string, len(d.Entries))
+	for _, e := range d.Entries {
+		if e.Kind == KindVar {
+			env[e.Key] = e.Value
+		}
+	}
+
+	return env
+}
+
+// Vars returns the entry behind each key of Map.
+func (d *Document) Vars() map[string]Entry {
+	vars := make(map[string]Entry, len(d.Entries))
+	for _, e := range d.Entries {
+		if e.Kind == KindVar {
+			vars[e.Key] = e
+		}
+	}
+
+	return vars
+}
+
+func (d *Document) String() string {
+	var b strings.Builder
+	for _, e := range d.Entries {
+		b.WriteString(e.Raw)
+	}
+
+	return b.String()
+}
@@ -4,4 +4,22 @@ import "fmt"
 
 var (
 	ErrFileDoesNotExist = fmt.Errorf("file does not exist")
+	ErrLineTooLong      = fmt.Errorf("line too long")
+	ErrDestOnlyKeys     = fmt.Errorf("destination has keys missing from source")
+	ErrEmptyKey         = fmt.Errorf("empty key")
+	ErrInvalidKey       = fmt.Errorf("invalid key")
+	ErrTypeMismatch     = fmt.Errorf("value does not match its type hint")
+	ErrWeakSecrets      = fmt.Errorf("destination has weak secrets")
+	ErrSourceConflict   = fmt.Errorf("source layers disagree on keys")
+	ErrDecrypt          = fmt.Errorf("cannot decrypt source")
+	ErrEncrypt          = fmt.Errorf("cannot encrypt source")
+	ErrTooManyChanges   = fmt.Errorf("force run changes more keys than allowed")
+	ErrParseWarnings    = fmt.Errorf("malformed lines were skipped")
+	ErrControlChar      = fmt.Errorf("value contains a control character")
+	ErrEmptyDestination = fmt.Errorf("destination has no keys yet")
+	ErrDeprecatedKeys   = fmt.Errorf("destination has deprecated keys")
+	ErrAborted          = fmt.Errorf("aborted")
+	ErrInterpolate      = fmt.Errorf("cannot interpolate value")
+	ErrMergeConflicts   = fmt.Errorf("keys changed in both source and destination since the last sync")
+	ErrDstModified      = fmt.Errorf("destination changed while envmerge was running")
 )
@@ -2,7 +2,11 @@ package field
 
 import "os"
 
+// File is a parsed destination. Path is where it lives; Dsc is its write
+// handle, nil until something is written.
 type File struct {
+	Path string
 	Dsc  *os.File
 	Data map[string]string
+	Doc  *Document
 }
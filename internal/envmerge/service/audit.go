@@ -0,0 +1,96 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// actorEnv names the environment variable that identifies who ran a sync
+// when --audit-actor is not given.
+const actorEnv = "ENVMERGE_ACTOR"
+
+// auditRecord is one line of the --audit-log file. PrevHash is the SHA-256 of
+// the previous line, so editing or dropping a record breaks the chain.
+type auditRecord struct {
+	Timestamp   string       `json:"timestamp"`
+	Actor       string       `json:"actor"`
+	Source      string       `json:"source"`
+	Destination string       `json:"destination"`
+	Added       []planValue  `json:"added"`
+	Updated     []planUpdate `json:"updated"`
+	PrevHash    string       `json:"prev_hash"`
+}
+
+func (s *Service) buildAuditRecord(plan Plan, now time.Time) auditRecord {
+	r := auditRecord{
+		Timestamp:   now.Format(time.RFC3339),
+		Actor:       s.auditActor,
+		Source:      s.srcName,
+		Destination: s.dstName,
+		Added:       []planValue{},
+		Updated:     []planUpdate{},
+	}
+	if r.Actor == "" {
+		r.Actor = os.Getenv(actorEnv)
+	}
+
+	keys := make([]string, 0, len(plan.Vars))
+	for k := range plan.Vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if old, ok := s.dst.Data[k]; ok {
+			r.Updated = append(r.Updated, planUpdate{Key: k, Old: s.mask(k, old), New: s.mask(k, plan.Vars[k])})
+		} else {
+			r.Added = append(r.Added, planValue{Key: k, Value: s.mask(k, plan.Vars[k])})
+		}
+	}
+
+	return r
+}
+
+// writeAudit appends the run to the audit log, chained to its last record.
+func (s *Service) writeAudit(plan Plan) error {
+	prev, err := os.ReadFile(s.auditLog)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error reading audit log: %w", err)
+	}
+
+	r := s.buildAuditRecord(plan, time.Now())
+	r.PrevHash = lastLineHash(prev)
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("error encoding audit record: %w", err)
+	}
+
+	f, err := os.OpenFile(s.auditLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open %q: %w", s.auditLog, err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// lastLineHash returns the hex SHA-256 of the last line in data, or "" for an
+// empty log.
+func lastLineHash(data []byte) string {
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256(data[bytes.LastIndexByte(data, '\n')+1:])
+	return hex.EncodeToString(sum[:])
+}
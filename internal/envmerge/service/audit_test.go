@@ -0,0 +1,79 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_buildAuditRecord(t *testing.T) {
+	t.Parallel()
+
+	secrets, err := parseKeyFilter(DefaultSecretPattern)
+	if err != nil {
+		t.Fatalf("parseKeyFilter: %v", err)
+	}
+
+	s := &Service{
+		srcName:    ".env.example",
+		dstName:    ".env",
+		auditActor: "ci",
+		secrets:    secrets,
+		dst:        &field.File{Data: map[string]string{"DB_PASSWORD": "old"}},
+	}
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	got := s.buildAuditRecord(Plan{Vars: map[string]string{"DB_PASSWORD": "new", "PORT": "80"}}, now)
+
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	want := `{"timestamp":"2024-01-02T03:04:05Z","actor":"ci","source":".env.example","destination":".env",` +
+		`"added":[{"key":"PORT","value":"80"}],"updated":[{"key":"DB_PASSWORD","old":"***","new":"***"}],"prev_hash":""}`
+	if string(data) != want {
+		t.Fatalf("got  %s\nwant %s", data, want)
+	}
+}
+
+func Test_writeAudit_chainsRecords(t *testing.T) {
+	t.Parallel()
+
+	s := &Service{
+		auditLog:   filepath.Join(t.TempDir(), ".env.audit.jsonl"),
+		auditActor: "dev",
+		dst:        &field.File{Data: map[string]string{}},
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := s.writeAudit(Plan{Vars: map[string]string{"A": "1"}}); err != nil {
+			t.Fatalf("writeAudit: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(mustReadFile(t, s.auditLog)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(lines))
+	}
+
+	prev := ""
+	for i, line := range lines {
+		var r auditRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+		if r.PrevHash != prev {
+			t.Fatalf("record %d: prev_hash %q, want %q", i, r.PrevHash, prev)
+		}
+
+		sum := sha256.Sum256([]byte(line))
+		prev = hex.EncodeToString(sum[:])
+	}
+}
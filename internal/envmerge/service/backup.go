@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// backupSuffix ends the name of every destination backup.
+const backupSuffix = ".bak"
+
+// backupNameEscaper turns a path into a single file name that can be read
+// back unambiguously.
+var backupNameEscaper = strings.NewReplacer("%", "%25", "/", "%2F", `\`, "%5C", ":", "%3A")
+
+// backupPath is where the destination is copied before it is written: next
+// to it as <dst>.bak, or with backupDir inside that directory, named after
+// the destination's absolute path and the time so backups of several files
+// and runs never collide.
+func (s *Service) backupPath(now time.Time) (string, error) {
+	if s.backupDir == "" {
+		return s.dst.Path + backupSuffix, nil
+	}
+
+	abs, err := filepath.Abs(s.dst.Path)
+	if err != nil {
+		return "", err
+	}
+	name := backupNameEscaper.Replace(abs) + "." + now.UTC().Format("20060102T150405.000000000Z") + backupSuffix
+
+	return filepath.Join(s.backupDir, name), nil
+}
+
+// backupDst copies the destination aside once per run, before its first
+// write. A destination that does not exist yet has nothing to back up.
+func (s *Service) backupDst() error {
+	if !s.backup || s.backedUp {
+		return nil
+	}
+
+	info, err := os.Stat(s.dst.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.backedUp = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error backing up destination: %w", err)
+	}
+	data, err := os.ReadFile(s.dst.Path)
+	if err != nil {
+		return fmt.Errorf("error backing up destination: %w", err)
+	}
+
+	path, err := s.backupPath(time.Now())
+	if err != nil {
+		return fmt.Errorf("error backing up destination: %w", err)
+	}
+	if s.backupDir != "" {
+		if err := os.MkdirAll(s.backupDir, 0o700); err != nil {
+			return fmt.Errorf("error creating backup directory: %w", err)
+		}
+	}
+	// The backup holds the same secrets, so it keeps the original's mode.
+	if err := os.WriteFile(path, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("error backing up destination: %w", err)
+	}
+
+	slog.Default().Info("destination backed up", "path", s.dst.Path, "backup", path)
+	s.backedUp = true
+	return nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+func Test_Run_backup(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		backupDir string
+	}{
+		{name: "alongside"},
+		{name: "backup dir", backupDir: filepath.Join("state", "backups")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			srcPath := filepath.Join(tmpDir, ".env.example")
+			dstPath := filepath.Join(tmpDir, ".env")
+			if err := os.WriteFile(srcPath, []byte("A=1\nB=2\n"), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if err := os.WriteFile(dstPath, []byte("A=1\n"), 0o640); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			cfg := config.Config{Src: srcPath, Dst: dstPath, Backup: true}
+			if tt.backupDir != "" {
+				cfg = config.Config{Src: srcPath, Dst: dstPath, BackupDir: filepath.Join(tmpDir, tt.backupDir)}
+			}
+			s, err := New(cfg)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if err := s.Run(); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			var backups []string
+			if tt.backupDir == "" {
+				backups = []string{dstPath + ".bak"}
+			} else {
+				entries, err := os.ReadDir(filepath.Join(tmpDir, tt.backupDir))
+				if err != nil {
+					t.Fatalf("backup dir: %v", err)
+				}
+				for _, e := range entries {
+					backups = append(backups, filepath.Join(tmpDir, tt.backupDir, e.Name()))
+				}
+				if _, err := os.Stat(dstPath + ".bak"); err == nil {
+					t.Fatalf("backup written next to the destination")
+				}
+			}
+			if len(backups) != 1 {
+				t.Fatalf("backups = %v, want one", backups)
+			}
+
+			if got := mustReadFile(t, backups[0]); got != "A=1\n" {
+				t.Fatalf("backup = %q, want the original destination", got)
+			}
+			info, err := os.Stat(backups[0])
+			if err != nil {
+				t.Fatalf("stat: %v", err)
+			}
+			if perm := info.Mode().Perm(); perm != 0o640 {
+				t.Fatalf("backup mode = %o, want 640", perm)
+			}
+			if tt.backupDir != "" {
+				name := filepath.Base(backups[0])
+				if want := backupNameEscaper.Replace(dstPath) + "."; !strings.HasPrefix(name, want) || !strings.HasSuffix(name, ".bak") {
+					t.Fatalf("backup name = %q, want %q<time>.bak", name, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_Run_backupSkipsUnwrittenDestination(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, ".env.example")
+	dstPath := filepath.Join(tmpDir, ".env")
+	if err := os.WriteFile(srcPath, []byte("A=1\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// The first run creates the destination, the second changes nothing:
+	// neither has anything to back up.
+	for run := 0; run < 2; run++ {
+		s, err := New(config.Config{Src: srcPath, Dst: dstPath, Backup: true})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if err := s.Run(); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+	}
+	if _, err := os.Stat(dstPath + ".bak"); err == nil {
+		t.Fatalf("backup written for an unchanged destination")
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+// Destinations splits a comma-separated --dst into its paths.
+func Destinations(dst string) []string {
+	var paths []string
+	for _, p := range strings.Split(dst, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+
+	return paths
+}
+
+// RunBatch syncs the source into every destination in dsts. A destination
+// that fails does not stop the others; the failures are joined into one error,
+// each prefixed with its path, and a summary is logged at the end. With
+// cfg.Transactional the batch is all-or-nothing instead, see runTransaction.
+func RunBatch(cfg config.Config, dsts []string) error {
+	if cfg.Out != "" && len(dsts) > 1 {
+		return errors.New("--out cannot be combined with several destinations")
+	}
+	if cfg.Transactional {
+		return runTransaction(cfg, dsts)
+	}
+
+	var errs []error
+	for _, dst := range dsts {
+		c := cfg
+		c.Dst = dst
+
+		s, err := New(c)
+		if err == nil {
+			err = s.Run()
+		}
+		if err != nil {
+			slog.Default().Error("destination failed", "path", dst, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", dst, err))
+		}
+	}
+
+	slog.Default().Info("batch finished",
+		"destinations", len(dsts),
+		"succeeded", len(dsts)-len(errs),
+		"failed", len(errs),
+	)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_Destinations(t *testing.T) {
+	t.Parallel()
+
+	got := Destinations(" a/.env, ,b/.env,")
+	if strings.Join(got, "|") != "a/.env|b/.env" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func Test_RunBatch_aggregatesFailures(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, ".env.example")
+	good := filepath.Join(tmpDir, "good.env")
+	broken := filepath.Join(tmpDir, "broken.env")
+	destOnly := filepath.Join(tmpDir, "dest-only.env")
+	for path, content := range map[string]string{
+		srcPath:  "A=1\n",
+		broken:   "NOT A LINE\n",
+		destOnly: "A=1\nLOCAL=1\n",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	cfg := config.Config{Src: srcPath, FailOnDestOnly: true}
+	err := RunBatch(cfg, []string{broken, good, destOnly})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	msg := err.Error()
+	if !strings.Contains(msg, broken+": ") || !strings.Contains(msg, destOnly+": ") {
+		t.Fatalf("error should name every failed destination: %v", err)
+	}
+	if strings.Contains(msg, good) {
+		t.Fatalf("error should not name the good destination: %v", err)
+	}
+	if !errors.Is(err, field.ErrDestOnlyKeys) {
+		t.Fatalf("joined error should wrap ErrDestOnlyKeys: %v", err)
+	}
+
+	if got := mustReadFile(t, good); !strings.Contains(got, "A=1\n") {
+		t.Fatalf("good destination should be synced, got %q", got)
+	}
+}
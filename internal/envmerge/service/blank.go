@@ -0,0 +1,28 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// printBlankKeys writes the source keys the destination is missing as bare
+// KEY= lines, in the order a sync block would list them: the still-to-fill
+// list for onboarding docs. No value is ever printed.
+func (s *Service) printBlankKeys() error {
+	w := s.stdout
+	if w == nil {
+		w = os.Stdout
+	}
+
+	keys, _ := s.blockKeys(s.determineNewVars())
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s=\n", k)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
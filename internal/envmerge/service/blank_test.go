@@ -0,0 +1,45 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+func Test_Run_blankValues(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, ".env.example")
+	dstPath := filepath.Join(tmpDir, ".env")
+	dst := "DB_HOST=localhost\nAPI_KEY=\n"
+	for path, content := range map[string]string{
+		srcPath: "DB_HOST=db\nDB_PASSWORD=hunter2\nAPI_KEY=changeme\nSECRET_TOKEN=s3cr3t\nPORT=5432\n",
+		dstPath: dst,
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	s, err := New(config.Config{Src: srcPath, Dst: dstPath, BlankValues: true, Exclude: "PORT", Force: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var out strings.Builder
+	s.stdout = &out
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// Present keys are not listed, even empty or differing ones.
+	if want := "DB_PASSWORD=\nSECRET_TOKEN=\n"; out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+	if got := mustReadFile(t, dstPath); got != dst {
+		t.Fatalf("destination changed: %q", got)
+	}
+}
@@ -0,0 +1,25 @@
+package service
+
+import "strings"
+
+// normalizeBool returns "true" or "false" for a value in the recognised
+// boolean set (true/false, yes/no, on/off, 1/0, any case), and any other
+// value unchanged.
+func normalizeBool(v string) string {
+	switch strings.ToLower(v) {
+	case "true", "yes", "on", "1":
+		return "true"
+	case "false", "no", "off", "0":
+		return "false"
+	}
+
+	return v
+}
+
+// normalizeBools rewrites the boolean values about to be written to their
+// canonical spelling.
+func (s *Service) normalizeBools(vars map[string]string) {
+	for k, v := range vars {
+		vars[k] = normalizeBool(v)
+	}
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_normalizeBool(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"yes":   "true",
+		"ON":    "true",
+		"1":     "true",
+		"True":  "true",
+		"0":     "false",
+		"false": "false",
+		"No":    "false",
+		"off":   "false",
+		"y":     "y",
+		"2":     "2",
+		"":      "",
+		"yes!":  "yes!",
+	}
+	for in, want := range cases {
+		if got := normalizeBool(in); got != want {
+			t.Errorf("normalizeBool(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func Test_Run_normalizeBools(t *testing.T) {
+	t.Parallel()
+
+	s := &Service{
+		force:             true,
+		normalizeBooleans: true,
+		src:               map[string]string{"DEBUG": "yes", "CACHE": "ON", "TLS": "0", "STRICT": "false", "NAME": "on call", "SAME": "true"},
+		dst:               &field.File{Data: map[string]string{"SAME": "1"}},
+	}
+
+	plan := s.Plan()
+	want := map[string]string{"DEBUG": "true", "CACHE": "true", "TLS": "false", "STRICT": "false", "NAME": "on call"}
+	if !mapsEqual(plan.Vars, want) {
+		t.Fatalf("plan = %v, want %v", plan.Vars, want)
+	}
+
+	var b strings.Builder
+	if err := s.Apply(plan, &b); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	for _, line := range []string{"DEBUG=true\n", "CACHE=true\n", "TLS=false\n", "NAME=\"on call\"\n"} {
+		if !strings.Contains(b.String(), line) {
+			t.Fatalf("block lacks %q:\n%s", line, b.String())
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+const cacheSchemaVersion = 1
+
+// runCache is the --cache file: the fingerprint of a run that found nothing
+// to do. While the configuration and every input file still match it, the
+// next run would find nothing either.
+type runCache struct {
+	SchemaVersion int          `json:"schema_version"`
+	Config        string       `json:"config"`
+	Files         []cachedFile `json:"files"`
+}
+
+type cachedFile struct {
+	Path    string `json:"path"`
+	Missing bool   `json:"missing,omitempty"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+	SHA256  string `json:"sha256"`
+}
+
+// cacheable reports whether cfg is a plain merge whose only effect is on the
+// destination, so skipping it changes nothing. Runs that print or append
+// something every time, warn about the files (--warn-similar, layer
+// conflicts), or read inputs the fingerprint cannot cover (git refs, the
+// environment, @file references), always run in full.
+func cacheable(cfg config.Config) bool {
+	if cfg.Cache == "" || !merges(cfg) || len(Destinations(cfg.Dst)) != 1 {
+		return false
+	}
+	if cfg.DryRun || cfg.Format == formatSh || cfg.PrintEffective || cfg.Checksum || cfg.Interactive || cfg.Trace || cfg.BlankValues || cfg.CheckSecrets || cfg.Interpolate {
+		return false
+	}
+	if cfg.ExpandFileRefs || cfg.WarnSimilar || cfg.DetectConflicts || cfg.FailOnConflict {
+		return false
+	}
+	if cfg.Out != "" || cfg.Patch != "" || cfg.ReportFile != "" || cfg.AuditLog != "" || cfg.Manifest != "" || cfg.ConflictsOut != "" {
+		return false
+	}
+	for _, name := range strings.Split(cfg.Src, ",") {
+		if strings.HasPrefix(strings.TrimSpace(name), gitSourcePrefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cacheInputs lists every file a merge with cfg reads.
+func cacheInputs(cfg config.Config) []string {
+	var paths []string
+	for _, name := range strings.Split(cfg.Src, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			paths = append(paths, name)
+		}
+	}
+	for _, name := range []string{cfg.Overlay, cfg.KeysFile, cfg.ForceKeys, cfg.RenameFile, cfg.MapFile, cfg.DstTemplate} {
+		if name != "" {
+			paths = append(paths, name)
+		}
+	}
+	paths = append(paths, cfg.Dst)
+	if cfg.Lock || cfg.ThreeWay {
+		paths = append(paths, cfg.Dst+".lock")
+	}
+
+	return paths
+}
+
+// configHash identifies the options of a run, so a cache taken with other
+// flags never matches.
+func configHash(cfg config.Config) (string, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// statFile fingerprints path by size and modification time only.
+func statFile(path string) (cachedFile, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cachedFile{Path: path, Missing: true}, nil
+	}
+	if err != nil {
+		return cachedFile{}, err
+	}
+
+	return cachedFile{Path: path, Size: info.Size(), ModTime: info.ModTime().UnixNano()}, nil
+}
+
+// hashFile adds the content hash to a fingerprint from statFile.
+func hashFile(f *cachedFile) error {
+	if f.Missing {
+		return nil
+	}
+
+	in, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, in); err != nil {
+		return err
+	}
+	f.SHA256 = hex.EncodeToString(h.Sum(nil))
+
+	return nil
+}
+
+// fingerprint describes cfg and its inputs as they are now.
+func fingerprint(cfg config.Config) (runCache, error) {
+	hash, err := configHash(cfg)
+	if err != nil {
+		return runCache{}, err
+	}
+
+	c := runCache{SchemaVersion: cacheSchemaVersion, Config: hash}
+	for _, path := range cacheInputs(cfg) {
+		f, err := statFile(path)
+		if err == nil {
+			err = hashFile(&f)
+		}
+		if err != nil {
+			return runCache{}, err
+		}
+		c.Files = append(c.Files, f)
+	}
+
+	return c, nil
+}
+
+// cacheFresh reports whether the --cache file still describes cfg and its
+// inputs. Sizes and modification times are compared first, so a changed
+// file is caught without reading it; contents are hashed only when they
+// all match.
+func cacheFresh(cfg config.Config) bool {
+	data, err := os.ReadFile(cfg.Cache)
+	if err != nil {
+		return false
+	}
+
+	var cached runCache
+	if err := json.Unmarshal(data, &cached); err != nil || cached.SchemaVersion != cacheSchemaVersion {
+		return false
+	}
+	if hash, err := configHash(cfg); err != nil || hash != cached.Config {
+		return false
+	}
+
+	inputs := cacheInputs(cfg)
+	if len(inputs) != len(cached.Files) {
+		return false
+	}
+	current := make([]cachedFile, len(inputs))
+	for i, path := range inputs {
+		f, err := statFile(path)
+		want := cached.Files[i]
+		if err != nil || f.Path != want.Path || f.Missing != want.Missing || f.Size != want.Size || f.ModTime != want.ModTime {
+			return false
+		}
+		current[i] = f
+	}
+	for i := range current {
+		if err := hashFile(&current[i]); err != nil || current[i].SHA256 != cached.Files[i].SHA256 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SkipIfUnchanged reports whether a run with cfg can be skipped because its
+// --cache file shows the last run found nothing to do and no input has
+// changed since. Skipped runs still report "changed=false" to GitHub Actions.
+func SkipIfUnchanged(cfg config.Config) (bool, error) {
+	if !cacheable(cfg) || !cacheFresh(cfg) {
+		return false, nil
+	}
+
+	slog.Default().Info("inputs unchanged since the cached run, nothing to do", "cache", cfg.Cache)
+	if path := os.Getenv(githubOutputEnv); path != "" {
+		if err := appendGitHubOutput(path, 0, 0, false); err != nil {
+			return true, fmt.Errorf("error writing GitHub Actions output: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+// writeCache records the fingerprint of a run that changed nothing. A cache
+// that cannot be written only costs the fast path, so errors are logged.
+func (s *Service) writeCache() {
+	// A skipped run would drop the warnings this one logged.
+	if s.cacheConfig == nil || len(s.parseWarnings) > 0 || len(s.deprecatedKeys()) > 0 {
+		return
+	}
+
+	c, err := fingerprint(*s.cacheConfig)
+	if err == nil {
+		var data []byte
+		if data, err = json.MarshalIndent(c, "", "  "); err == nil {
+			err = os.WriteFile(s.cacheConfig.Cache, append(data, '\n'), 0o644)
+		}
+	}
+	if err != nil {
+		slog.Default().Warn("cannot write cache", "path", s.cacheConfig.Cache, "error", err)
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+// cacheFixture writes a source and a destination that is already in sync.
+func cacheFixture(t testing.TB, content string) config.Config {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	cfg := config.Config{
+		Src:   filepath.Join(tmpDir, ".env.example"),
+		Dst:   filepath.Join(tmpDir, ".env"),
+		Cache: filepath.Join(tmpDir, ".envmerge-cache"),
+	}
+	for _, path := range []string{cfg.Src, cfg.Dst} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	return cfg
+}
+
+func runOnce(t testing.TB, cfg config.Config) {
+	t.Helper()
+
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func Test_SkipIfUnchanged(t *testing.T) {
+	ghOutput := filepath.Join(t.TempDir(), "github_output")
+	t.Setenv(githubOutputEnv, ghOutput)
+
+	cfg := cacheFixture(t, "A=1\nB=2\n")
+	skip := func() bool {
+		t.Helper()
+		ok, err := SkipIfUnchanged(cfg)
+		if err != nil {
+			t.Fatalf("SkipIfUnchanged: %v", err)
+		}
+		return ok
+	}
+
+	if skip() {
+		t.Fatal("no cache yet, the run must not be skipped")
+	}
+	runOnce(t, cfg)
+	if !skip() {
+		t.Fatal("nothing changed since a no-op run, it should be skipped")
+	}
+	if got := mustReadFile(t, ghOutput); !strings.Contains(got, "changed=false\n") {
+		t.Fatalf("skipped run should still report to GitHub Actions, got %q", got)
+	}
+
+	other := cfg
+	other.Force = true
+	if ok, _ := SkipIfUnchanged(other); ok {
+		t.Fatal("a cache taken with other options must not match")
+	}
+
+	// Same size and modification time, different content.
+	info, err := os.Stat(cfg.Src)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if err := os.WriteFile(cfg.Src, []byte("A=1\nB=3\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Chtimes(cfg.Src, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if skip() {
+		t.Fatal("changed content must force a full run")
+	}
+
+	// Same content, touched.
+	if err := os.WriteFile(cfg.Src, []byte("A=1\nB=2\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(cfg.Src, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if skip() {
+		t.Fatal("a changed modification time must invalidate the cache")
+	}
+}
+
+func Test_Run_cacheOnlyAfterNoChange(t *testing.T) {
+	t.Parallel()
+
+	cfg := cacheFixture(t, "A=1\n")
+	if err := os.WriteFile(cfg.Dst, nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	runOnce(t, cfg)
+	if _, err := os.Stat(cfg.Cache); !os.IsNotExist(err) {
+		t.Fatalf("a run that wrote keys must not be cached, stat err = %v", err)
+	}
+
+	runOnce(t, cfg)
+	if !cacheFresh(cfg) {
+		t.Fatal("the no-op run after it should be cached")
+	}
+}
+
+func Test_Run_cacheSkippedWhileWarning(t *testing.T) {
+	t.Parallel()
+
+	// The destination still uses a deprecated key, so every run has to
+	// warn about it again.
+	cfg := cacheFixture(t, "# envmerge:deprecated use B\nA=1\nB=2\n")
+	runOnce(t, cfg)
+	runOnce(t, cfg)
+	if _, err := os.Stat(cfg.Cache); !os.IsNotExist(err) {
+		t.Fatalf("a run that warned must not be cached, stat err = %v", err)
+	}
+}
+
+func Test_cacheable(t *testing.T) {
+	t.Parallel()
+
+	base := config.Config{Src: ".env.example", Dst: ".env", Cache: ".cache"}
+	if !cacheable(base) {
+		t.Fatal("a plain run should be cacheable")
+	}
+
+	for name, change := range map[string]func(*config.Config){
+		"no cache":     func(c *config.Config) { c.Cache = "" },
+		"dry run":      func(c *config.Config) { c.DryRun = true },
+		"audit log":    func(c *config.Config) { c.AuditLog = "audit.jsonl" },
+		"several dsts": func(c *config.Config) { c.Dst = "a/.env,b/.env" },
+		"git source":   func(c *config.Config) { c.Src = "git:HEAD:.env.example" },
+		"interpolate":  func(c *config.Config) { c.Interpolate = true },
+		"fmt":          func(c *config.Config) { c.Fmt = true },
+		"file refs":    func(c *config.Config) { c.ExpandFileRefs = true },
+		"warn similar": func(c *config.Config) { c.WarnSimilar = true },
+	} {
+		cfg := base
+		change(&cfg)
+		if cacheable(cfg) {
+			t.Errorf("%s: should not be cacheable", name)
+		}
+	}
+}
+
+func BenchmarkNoChangeRun(b *testing.B) {
+	var content strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&content, "# key %d\nKEY_%d=\"value %d\"\n", i, i, i)
+	}
+
+	cfg := cacheFixture(b, content.String())
+	runOnce(b, cfg)
+
+	b.Run("full", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			runOnce(b, cfg)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if ok, err := SkipIfUnchanged(cfg); err != nil || !ok {
+				b.Fatalf("SkipIfUnchanged = %v, %v", ok, err)
+			}
+		}
+	})
+}
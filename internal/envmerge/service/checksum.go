@@ -0,0 +1,33 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// envChecksum returns the hex SHA-256 of env: each key and value in key
+// order, NUL-terminated, so neither file order nor comments affect it and no
+// two environments share an input.
+func envChecksum(env map[string]string) string {
+	h := sha256.New()
+	for _, k := range sortedKeys(env) {
+		_, _ = io.WriteString(h, k+"\x00"+env[k]+"\x00")
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// printChecksum writes the checksum of the environment the destination will
+// hold after plan. Secret values count towards it but are never printed.
+func (s *Service) printChecksum(plan Plan) error {
+	w := s.stdout
+	if w == nil {
+		w = os.Stdout
+	}
+
+	_, err := fmt.Fprintln(w, envChecksum(s.effectiveEnv(plan)))
+	return err
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_printChecksum(t *testing.T) {
+	t.Parallel()
+
+	checksum := func(dst string, src map[string]string) string {
+		t.Helper()
+
+		doc, err := parseDocument(strings.NewReader(dst))
+		if err != nil {
+			t.Fatalf("parseDocument: %v", err)
+		}
+
+		var out strings.Builder
+		s := &Service{checksum: true, stdout: &out, src: src, dst: &field.File{Data: doc.Map(), Doc: doc}}
+		if err := s.Run(); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		return out.String()
+	}
+
+	src := map[string]string{"A": "1", "DB_PASSWORD": "hunter2"}
+	first := checksum("B=2\n", src)
+	if len(first) != 65 || !strings.HasSuffix(first, "\n") {
+		t.Fatalf("unexpected checksum %q", first)
+	}
+	if strings.Contains(first, "hunter2") {
+		t.Fatal("checksum output leaks a secret")
+	}
+
+	if again := checksum("B=2\n", src); again != first {
+		t.Fatalf("same input, different checksums: %q vs %q", first, again)
+	}
+	if reordered := checksum("# note\nDB_PASSWORD=hunter2\n\n# envmerge sync run: 2024-01-01 00:00:00\nA=1\nB=2\n", nil); reordered != first {
+		t.Fatalf("order or comments changed the checksum: %q vs %q", first, reordered)
+	}
+	if changed := checksum("B=3\n", src); changed == first {
+		t.Fatal("a changed value kept the checksum")
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+const (
+	syncHeaderPrefix  = "# envmerge sync run"
+	summaryLinePrefix = "# envmerge: "
+)
+
+// Compact collapses every sync block of the destination into one. Keys that
+// are also defined outside the blocks are updated there instead, so each key
+// ends up defined once with the value it had before. The result keeps the
+// last header, which makes a second run a no-op.
+func (s *Service) Compact() error {
+	defer func() {
+		if s.dst != nil && s.dst.Dsc != nil {
+			_ = s.dst.Dsc.Close()
+		}
+	}()
+
+	compacted := s.compactDocument(s.dst.Doc)
+	if compacted == s.dst.Doc.String() {
+		slog.Default().Info("dotenv already compact")
+		return nil
+	}
+
+	if err := s.rewrite(compacted); err != nil {
+		return fmt.Errorf("error writing compacted destination: %w", err)
+	}
+
+	slog.Default().Info("dotenv compacted")
+	return nil
+}
+
+// compactDocument renders doc with its sync blocks merged. A block runs from
+// its header to the next header, the insert marker or the end of the file;
+// the merged block goes where the first one was.
+func (s *Service) compactDocument(doc *field.Document) string {
+	var (
+		kept    []field.Entry
+		blocks  = make(map[string]field.Entry)
+		header  string
+		blockAt = -1
+		inBlock bool
+	)
+
+	for _, e := range doc.Entries {
+		trimmed := strings.TrimSpace(e.Raw)
+		switch {
+		case e.Kind == field.KindComment && strings.HasPrefix(trimmed, syncHeaderPrefix):
+			if blockAt < 0 {
+				blockAt = len(kept)
+			}
+			header = trimmed
+			inBlock = true
+			continue
+		case e.Kind == field.KindComment && trimmed == insertMarker:
+			inBlock = false
+		}
+
+		if !inBlock {
+			kept = append(kept, e)
+			continue
+		}
+		if e.Kind == field.KindVar {
+			blocks[e.Key] = e
+		}
+	}
+
+	if blockAt < 0 {
+		return doc.String()
+	}
+
+	effective := doc.Map()
+
+	// Keys also defined outside the blocks are updated in place, at their
+	// last definition there.
+	last := make(map[string]int)
+	for i, e := range kept {
+		if e.Kind == field.KindVar {
+			last[e.Key] = i
+		}
+	}
+	for k := range blocks {
+		i, ok := last[k]
+		if !ok {
+			continue
+		}
+
+		if kept[i].Value != effective[k] {
+			kept[i] = s.compactEntry(kept[i], effective[k])
+		}
+		delete(blocks, k)
+	}
+
+	head, tail := kept[:blockAt], kept[blockAt:]
+	for len(head) > 0 && head[len(head)-1].Kind == field.KindBlank {
+		head = head[:len(head)-1]
+	}
+	for len(tail) > 0 && tail[0].Kind == field.KindBlank {
+		tail = tail[1:]
+	}
+
+	var b strings.Builder
+	for _, e := range head {
+		b.WriteString(e.Raw)
+	}
+
+	if len(blocks) > 0 {
+		if b.Len() > 0 {
+			if !strings.HasSuffix(b.String(), "\n") {
+				b.WriteString(s.eol())
+			}
+			b.WriteString(s.eol())
+		}
+		b.WriteString(header + s.eol())
+
+		keys := make([]string, 0, len(blocks))
+		for k := range blocks {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			e := blocks[k]
+			for _, c := range e.Doc {
+				if !strings.HasPrefix(c, syncHeaderPrefix) && !strings.HasPrefix(c, summaryLinePrefix) {
+					b.WriteString(c + s.eol())
+				}
+			}
+			b.WriteString(s.compactEntry(e, effective[k]).Raw)
+		}
+	}
+
+	if len(tail) > 0 {
+		if b.Len() > 0 {
+			if !strings.HasSuffix(b.String(), "\n") {
+				b.WriteString(s.eol())
+			}
+			b.WriteString(s.eol())
+		}
+		for _, e := range tail {
+			b.WriteString(e.Raw)
+		}
+	}
+
+	return b.String()
+}
+
+// compactEntry returns e rewritten to hold value, keeping its indentation,
+// export prefix, quoting style and inline comment.
+func (s *Service) compactEntry(e field.Entry, value string) field.Entry {
+	value, comment := splitInlineComment(e, value)
+	formatted := s.formatValue(value)
+	if e.Quoted {
+		formatted = quoteEnvValue(value)
+	}
+
+	prefix := ""
+	if e.Export {
+		prefix = exportPrefix
+	}
+
+	indent := e.Raw[:len(e.Raw)-len(strings.TrimLeft(e.Raw, " \t"))]
+	line := fmt.Sprintf("%s%s%s=%s%s\n", indent, prefix, e.Key, formatted, comment)
+	if s.lineEnding != "" && s.lineEnding != "\n" {
+		line = strings.ReplaceAll(line, "\n", s.lineEnding)
+	}
+
+	e.Raw = line
+	e.Value = value
+	return e
+}
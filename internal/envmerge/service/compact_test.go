@@ -0,0 +1,120 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_compactDocument(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "no blocks",
+			in:   "A=1\n\nB=2\n",
+			want: "A=1\n\nB=2\n",
+		},
+		{
+			name: "blocks merged under last header",
+			in: "A=1\n" +
+				"\n# envmerge sync run: 2024-01-01 00:00:00\nC=3\nB=2\n" +
+				"\n# envmerge sync run (force): 2024-02-01 00:00:00\n# envmerge: +1 keys, ~1 updated from .env.example\nC=33\nD=4\n",
+			want: "A=1\n\n# envmerge sync run (force): 2024-02-01 00:00:00\nB=2\nC=33\nD=4\n",
+		},
+		{
+			name: "original updated in place",
+			in:   "A=1\nexport B=\"x\"\n\n# envmerge sync run (force): 2024-01-01 00:00:00\nB=new value\n",
+			want: "A=1\nexport B=\"new value\"\n",
+		},
+		{
+			name: "inline comments kept",
+			in:   "A=\"x\" # note\nT=1 # type:int\n\n# envmerge sync run (force): 2024-01-01 00:00:00\nA=y\nT=2 # type:int\n",
+			want: "A=\"y\" # note\nT=2 # type:int\n",
+		},
+		{
+			name: "carried comments kept",
+			in:   "A=1\n\n# envmerge sync run: 2024-01-01 00:00:00\n# the port\nPORT=80\n",
+			want: "A=1\n\n# envmerge sync run: 2024-01-01 00:00:00\n# the port\nPORT=80\n",
+		},
+		{
+			name: "block ends at the insert marker",
+			in: "A=1\n\n# envmerge sync run: 2024-01-01 00:00:00\nB=2\n\n# envmerge sync run: 2024-02-01 00:00:00\nC=3\n\n" +
+				insertMarker + "\n\nLOCAL=1\n",
+			want: "A=1\n\n# envmerge sync run: 2024-02-01 00:00:00\nB=2\nC=3\n\n" + insertMarker + "\n\nLOCAL=1\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			doc, err := parseDocument(strings.NewReader(tc.in))
+			if err != nil {
+				t.Fatalf("parseDocument: %v", err)
+			}
+
+			s := &Service{}
+			got := s.compactDocument(doc)
+			if got != tc.want {
+				t.Fatalf("compactDocument mismatch\ngot:  %q\nwant: %q", got, tc.want)
+			}
+
+			before, after := doc.Map(), mustParseString(t, got)
+			if !mapsEqual(before, after) {
+				t.Fatalf("values changed: before %#v, after %#v", before, after)
+			}
+		})
+	}
+}
+
+func Test_Compact_idempotent(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	dstPath := filepath.Join(tmpDir, ".env")
+
+	in := "A=1\n\n# envmerge sync run: 2024-01-01 00:00:00\nB=2\n\n# envmerge sync run: 2024-02-01 00:00:00\nA=2\nC=3\n"
+	if err := os.WriteFile(dstPath, []byte(in), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	compact := func() string {
+		t.Helper()
+
+		f, err := readDstFile(tmpDir, ".env", parser{})
+		if err != nil {
+			t.Fatalf("readDstFile: %v", err)
+		}
+
+		s := &Service{dst: f}
+		if err := s.Compact(); err != nil {
+			t.Fatalf("Compact: %v", err)
+		}
+
+		return mustReadFile(t, dstPath)
+	}
+
+	first := compact()
+	if want := "A=2\n\n# envmerge sync run: 2024-02-01 00:00:00\nB=2\nC=3\n"; first != want {
+		t.Fatalf("got %q, want %q", first, want)
+	}
+	if second := compact(); second != first {
+		t.Fatalf("second compact changed the file:\n%q\n%q", first, second)
+	}
+}
+
+func mustParseString(t *testing.T, content string) map[string]string {
+	t.Helper()
+
+	got, err := fileContent(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("fileContent: %v", err)
+	}
+	return got
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// sourceLayer is one --src file as read, before layers are merged.
+type sourceLayer struct {
+	name string
+	env  map[string]string
+}
+
+// sourceConflict is a key that source layers define with different values.
+// Files lists every layer defining the key, lowest precedence first; the
+// last one wins.
+type sourceConflict struct {
+	Key   string
+	Files []string
+}
+
+// layerConflicts returns the keys, sorted, that at least two layers define
+// with different values.
+func layerConflicts(layers []sourceLayer) []sourceConflict {
+	var keys []string
+	seen := make(map[string]struct{})
+	for _, l := range layers {
+		for k := range l.env {
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	var conflicts []sourceConflict
+	for _, k := range keys {
+		var (
+			files    []string
+			first    string
+			differs  bool
+			hasFirst bool
+		)
+		for _, l := range layers {
+			v, ok := l.env[k]
+			if !ok {
+				continue
+			}
+
+			files = append(files, l.name)
+			if !hasFirst {
+				first, hasFirst = v, true
+			} else if v != first {
+				differs = true
+			}
+		}
+
+		if differs {
+			conflicts = append(conflicts, sourceConflict{Key: k, Files: files})
+		}
+	}
+
+	return conflicts
+}
+
+// checkConflicts warns about every conflict between layers, without logging
+// values since they may be secrets. With fail set, conflicts are an error.
+func checkConflicts(layers []sourceLayer, fail bool) error {
+	conflicts := layerConflicts(layers)
+	for _, c := range conflicts {
+		slog.Default().Warn("source layers disagree on key, last one wins", "key", c.Key, "files", c.Files)
+	}
+
+	if !fail || len(conflicts) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(conflicts))
+	for i, c := range conflicts {
+		keys[i] = c.Key
+	}
+	return fmt.Errorf("%w: %s", field.ErrSourceConflict, strings.Join(keys, ", "))
+}
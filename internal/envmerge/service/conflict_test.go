@@ -0,0 +1,56 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_layerConflicts(t *testing.T) {
+	t.Parallel()
+
+	got := layerConflicts([]sourceLayer{
+		{name: "a", env: map[string]string{"SAME": "1", "DIFF": "a", "ONLY_A": "x"}},
+		{name: "b", env: map[string]string{"SAME": "1", "LATE": "b"}},
+		{name: "c", env: map[string]string{"SAME": "1", "DIFF": "c", "LATE": "c"}},
+	})
+	want := []sourceConflict{
+		{Key: "DIFF", Files: []string{"a", "c"}},
+		{Key: "LATE", Files: []string{"b", "c"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v; want %#v", got, want)
+	}
+}
+
+func Test_readSources_conflicts(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		".env.defaults": &fstest.MapFile{Data: []byte("A=1\nB=default\n")},
+		".env.example":  &fstest.MapFile{Data: []byte("A=1\nB=example\n")},
+		".env.prod":     &fstest.MapFile{Data: []byte("A=prod\n")},
+	}
+	cfg := config.Config{Src: ".env.defaults,.env.example", Overlay: ".env.prod", DetectConflicts: true}
+
+	got, err := readSources("", fsys, cfg, parser{})
+	if err != nil {
+		t.Fatalf("detect only should not fail: %v", err)
+	}
+	if !mapsEqual(got.Map(), map[string]string{"A": "prod", "B": "example"}) {
+		t.Fatalf("last wins should still apply, got %#v", got.Map())
+	}
+
+	cfg.FailOnConflict = true
+	_, err = readSources("", fsys, cfg, parser{})
+	if !errors.Is(err, field.ErrSourceConflict) {
+		t.Fatalf("expected ErrSourceConflict, got %v", err)
+	}
+	if err.Error() != field.ErrSourceConflict.Error()+": B" {
+		t.Fatalf("only B conflicts between layers, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// Values of --control-chars.
+const (
+	controlCharsReject = "reject"
+	controlCharsStrip  = "strip"
+)
+
+// isControlChar reports whether r is a control character a value must not
+// hold; tabs and line breaks are allowed.
+func isControlChar(r rune) bool {
+	switch r {
+	case '\t', '\n', '\r':
+		return false
+	}
+
+	return r < 0x20 || r == 0x7f
+}
+
+// checkControlChars rejects a value holding control characters such as NUL or
+// BEL, or with stripControl removes them from e.Value.
+func (p parser) checkControlChars(e *field.Entry) error {
+	i := strings.IndexFunc(e.Value, isControlChar)
+	if i < 0 {
+		return nil
+	}
+
+	if p.stripControl {
+		e.Value = strings.Map(func(r rune) rune {
+			if isControlChar(r) {
+				return -1
+			}
+			return r
+		}, e.Value)
+		return nil
+	}
+
+	return fmt.Errorf("%w on line %d: key %q holds %q", field.ErrControlChar, e.Line, e.Key, e.Value[i])
+}
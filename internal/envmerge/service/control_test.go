@@ -0,0 +1,53 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_parser_controlChars(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		content string
+		want    map[string]string
+	}{
+		{name: "nul unquoted", content: "A=ab\x00c\n", want: map[string]string{"A": "abc"}},
+		{name: "bell quoted", content: "A=\"ding\x07 dong\"\n", want: map[string]string{"A": "ding dong"}},
+		{name: "nul in multiline", content: "A=\"x\ny\x00\"\nB=1\n", want: map[string]string{"A": "x\ny", "B": "1"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parser{}.content(strings.NewReader(tc.content))
+			if !errors.Is(err, field.ErrControlChar) {
+				t.Fatalf("reject: expected ErrControlChar, got %v", err)
+			}
+			if !strings.Contains(err.Error(), `key "A"`) {
+				t.Fatalf("reject: error does not name the key: %v", err)
+			}
+
+			got, err := parser{stripControl: true}.content(strings.NewReader(tc.content))
+			if err != nil {
+				t.Fatalf("strip: %v", err)
+			}
+			if !mapsEqual(got, tc.want) {
+				t.Fatalf("strip: got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	got, err := parser{}.content(strings.NewReader("A=a\tb\r\nB=\"x\ny\"\n"))
+	if err != nil {
+		t.Fatalf("tabs and line breaks: %v", err)
+	}
+	if !mapsEqual(got, map[string]string{"A": "a\tb", "B": "x\ny"}) {
+		t.Fatalf("tabs and line breaks: got %q", got)
+	}
+}
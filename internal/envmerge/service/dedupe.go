@@ -0,0 +1,118 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// Dedupe rewrites the destination keeping only the last definition of each
+// key, where it is, which is the value reads already see. Sync blocks left
+// without keys are dropped with their headers, so a second run is a no-op.
+func (s *Service) Dedupe() error {
+	defer func() {
+		if s.dst != nil && s.dst.Dsc != nil {
+			_ = s.dst.Dsc.Close()
+		}
+	}()
+
+	deduped := dedupeDocument(s.dst.Doc)
+	if deduped == s.dst.Doc.String() {
+		slog.Default().Info("dotenv has no duplicate keys")
+		return nil
+	}
+
+	if err := s.rewrite(deduped); err != nil {
+		return fmt.Errorf("error writing deduplicated destination: %w", err)
+	}
+
+	slog.Default().Info("dotenv deduplicated")
+	return nil
+}
+
+// dedupeDocument renders doc without the earlier definitions of repeated keys.
+// Inside sync blocks, the comments envmerge carried above a dropped
+// definition go with it, and a block with no definitions left is dropped
+// whole, along with the blank lines before its header. Blocks are delimited
+// as in compactDocument.
+func dedupeDocument(doc *field.Document) string {
+	entries := doc.Entries
+
+	last := make(map[string]int)
+	blockOf := make([]int, len(entries))
+	blockEnd := make(map[int]int)
+	block := -1
+	for i, e := range entries {
+		trimmed := strings.TrimSpace(e.Raw)
+		switch {
+		case e.Kind == field.KindComment && strings.HasPrefix(trimmed, syncHeaderPrefix):
+			block = i
+		case e.Kind == field.KindComment && trimmed == insertMarker:
+			block = -1
+		}
+		blockOf[i] = block
+		if block >= 0 && e.Kind != field.KindBlank {
+			blockEnd[block] = i
+		}
+
+		if e.Kind == field.KindVar {
+			last[e.Key] = i
+		}
+	}
+
+	drop := make([]bool, len(entries))
+	for i, e := range entries {
+		if e.Kind != field.KindVar || last[e.Key] == i {
+			continue
+		}
+
+		drop[i] = true
+		if blockOf[i] < 0 {
+			continue
+		}
+		for j, n := i-1, 0; j > blockOf[i] && n < len(e.Doc) && isCarriedComment(entries[j]); j, n = j-1, n+1 {
+			drop[j] = true
+		}
+	}
+
+	// A block survives while any of its definitions does.
+	live := make(map[int]bool)
+	for i, e := range entries {
+		if e.Kind == field.KindVar && !drop[i] && blockOf[i] >= 0 {
+			live[blockOf[i]] = true
+		}
+	}
+	for i, b := range blockOf {
+		// Blank lines after a dropped block's last line separate what
+		// follows, so they stay.
+		if b < 0 || live[b] || i > blockEnd[b] {
+			continue
+		}
+
+		drop[i] = true
+		if i == b {
+			for j := i - 1; j >= 0 && entries[j].Kind == field.KindBlank; j-- {
+				drop[j] = true
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i, e := range entries {
+		if !drop[i] {
+			b.WriteString(e.Raw)
+		}
+	}
+
+	return b.String()
+}
+
+// isCarriedComment reports whether e is a comment of a sync block other than
+// its header or summary line.
+func isCarriedComment(e field.Entry) bool {
+	trimmed := strings.TrimSpace(e.Raw)
+	return e.Kind == field.KindComment &&
+		!strings.HasPrefix(trimmed, syncHeaderPrefix) && !strings.HasPrefix(trimmed, summaryLinePrefix)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_dedupeDocument(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "no duplicates",
+			in:   "A=1\n\n# envmerge sync run: 2024-01-01 00:00:00\nB=2\n",
+			want: "A=1\n\n# envmerge sync run: 2024-01-01 00:00:00\nB=2\n",
+		},
+		{
+			name: "several duplicate blocks",
+			in: "A=1\nKEEP=x\n" +
+				"\n# envmerge sync run (force): 2024-01-01 00:00:00\n# the a key\nA=2\nB=1\n" +
+				"\n# envmerge sync run (force): 2024-02-01 00:00:00\n# envmerge: ~2 updated from .env.example\nA=3\nB=2\n" +
+				"\n# envmerge sync run (force): 2024-03-01 00:00:00\nA=4\nC=1\n",
+			want: "KEEP=x\n" +
+				"\n# envmerge sync run (force): 2024-02-01 00:00:00\n# envmerge: ~2 updated from .env.example\nB=2\n" +
+				"\n# envmerge sync run (force): 2024-03-01 00:00:00\nA=4\nC=1\n",
+		},
+		{
+			name: "last definition outside the blocks",
+			in:   "\n# envmerge sync run: 2024-01-01 00:00:00\nA=1\n\n" + insertMarker + "\n\nA=2\n",
+			want: "\n" + insertMarker + "\n\nA=2\n",
+		},
+		{
+			name: "user comments outside blocks kept",
+			in:   "# the port\nPORT=80\n# again\nPORT=81\n",
+			want: "# the port\n# again\nPORT=81\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			doc, err := parseDocument(strings.NewReader(tc.in))
+			if err != nil {
+				t.Fatalf("parseDocument: %v", err)
+			}
+
+			got := dedupeDocument(doc)
+			if got != tc.want {
+				t.Fatalf("dedupeDocument mismatch\ngot:  %q\nwant: %q", got, tc.want)
+			}
+			if !mapsEqual(mustParseString(t, got), doc.Map()) {
+				t.Fatalf("values changed: %v", mustParseString(t, got))
+			}
+
+			again, err := parseDocument(strings.NewReader(got))
+			if err != nil {
+				t.Fatalf("parseDocument: %v", err)
+			}
+			if second := dedupeDocument(again); second != got {
+				t.Fatalf("not idempotent\nfirst:  %q\nsecond: %q", got, second)
+			}
+		})
+	}
+}
+
+func Test_Dedupe(t *testing.T) {
+	t.Parallel()
+
+	dstPath := writeTempFile(t, "A=1\n\n# envmerge sync run (force): 2024-01-01 00:00:00\nA=2\n")
+	dst, err := readDstFile(filepath.Dir(dstPath), filepath.Base(dstPath), parser{})
+	if err != nil {
+		t.Fatalf("readDstFile: %v", err)
+	}
+
+	s := &Service{dst: dst}
+	if err := s.Dedupe(); err != nil {
+		t.Fatalf("Dedupe: %v", err)
+	}
+	if got := mustReadFile(t, dstPath); got != "\n# envmerge sync run (force): 2024-01-01 00:00:00\nA=2\n" {
+		t.Fatalf("got %q", got)
+	}
+}
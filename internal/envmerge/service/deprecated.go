@@ -0,0 +1,56 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// deprecatedAnnotation, above a source key, marks it as deprecated; the rest
+// of the line is the reason, e.g. "# envmerge:deprecated use NEW_KEY instead".
+const deprecatedAnnotation = "# envmerge:deprecated"
+
+// deprecation returns the reason of a deprecated source entry, and whether it
+// is deprecated at all.
+func deprecation(e field.Entry) (string, bool) {
+	for _, c := range e.Doc {
+		rest, ok := strings.CutPrefix(c, deprecatedAnnotation)
+		if ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			return strings.TrimSpace(rest), true
+		}
+	}
+
+	return "", false
+}
+
+// checkDeprecated warns about every destination key the source marks as
+// deprecated, and with failOnDeprecated fails on them. They are never
+// removed.
+func (s *Service) checkDeprecated() error {
+	keys := s.deprecatedKeys()
+	for _, k := range keys {
+		reason, _ := deprecation(s.srcEntries[k])
+		slog.Default().Warn("destination uses a deprecated key", "key", k, "reason", reason)
+	}
+
+	if s.failOnDeprecated && len(keys) > 0 {
+		return fmt.Errorf("%w: %s", field.ErrDeprecatedKeys, strings.Join(keys, ", "))
+	}
+
+	return nil
+}
+
+// deprecatedKeys returns, sorted, the destination keys the source marks as
+// deprecated.
+func (s *Service) deprecatedKeys() []string {
+	var keys []string
+	for _, k := range sortedKeys(s.dst.Data) {
+		if _, ok := deprecation(s.srcEntries[k]); ok {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
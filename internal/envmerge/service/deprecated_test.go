@@ -0,0 +1,63 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_deprecation(t *testing.T) {
+	t.Parallel()
+
+	doc, err := parseDocument(strings.NewReader(
+		"# envmerge:deprecated use DATABASE_URL instead\nDB_HOST=db\n" +
+			"# the url\n# envmerge:deprecated\nOLD_URL=x\n" +
+			"# envmerge:deprecatedness is not a thing\nFINE=1\n"))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+
+	cases := []struct {
+		key        string
+		wantReason string
+		wantOK     bool
+	}{
+		{key: "DB_HOST", wantReason: "use DATABASE_URL instead", wantOK: true},
+		{key: "OLD_URL", wantOK: true},
+		{key: "FINE"},
+	}
+	vars := doc.Vars()
+	for _, tc := range cases {
+		reason, ok := deprecation(vars[tc.key])
+		if reason != tc.wantReason || ok != tc.wantOK {
+			t.Errorf("%s: got (%q, %v), want (%q, %v)", tc.key, reason, ok, tc.wantReason, tc.wantOK)
+		}
+	}
+}
+
+func Test_Run_failOnDeprecated(t *testing.T) {
+	t.Parallel()
+
+	srcDoc, err := parseDocument(strings.NewReader("# envmerge:deprecated use NEW instead\nOLD=1\nNEW=2\n"))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+
+	s := &Service{
+		failOnDeprecated: true,
+		src:              srcDoc.Map(),
+		srcEntries:       srcDoc.Vars(),
+		dst:              &field.File{Data: map[string]string{"OLD": "1"}},
+	}
+	err = s.Run()
+	if !errors.Is(err, field.ErrDeprecatedKeys) || !strings.Contains(err.Error(), "OLD") {
+		t.Fatalf("expected ErrDeprecatedKeys naming OLD, got %v", err)
+	}
+
+	s.dst = &field.File{Data: map[string]string{"NEW": "2"}}
+	if err := s.checkDeprecated(); err != nil {
+		t.Fatalf("checkDeprecated without deprecated keys: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// envDiff is the --diff result: the plan that would turn the first file into
+// the second, plus the keys only the first one has, with their values.
+type envDiff struct {
+	jsonPlan
+	Removed []planValue `json:"removed"`
+}
+
+// Diff compares the two files in cfg.DiffFiles and writes the differences to
+// w, without merging or writing anything: keys only in the second file are
+// added, keys only in the first removed and keys in both with different
+// values changed. The output follows cfg.Format like the dry-run plan.
+func Diff(cfg config.Config, w io.Writer) error {
+	if len(cfg.DiffFiles) != 2 {
+		return fmt.Errorf("--diff needs exactly two files, got %d", len(cfg.DiffFiles))
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("cannot determine caller dir: %w", err)
+	}
+
+	s, err := configure(cfg, dir)
+	if err != nil {
+		return err
+	}
+	s.stdout = w
+
+	p := parserFor(cfg)
+	a, err := readSrcFile(dir, cfg.DiffFiles[0], p)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", cfg.DiffFiles[0], err)
+	}
+	b, err := readSrcFile(dir, cfg.DiffFiles[1], p)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", cfg.DiffFiles[1], err)
+	}
+
+	s.dst = &field.File{Data: a.Map(), Doc: a}
+	s.src = b.Map()
+
+	return s.printDiff()
+}
+
+// printDiff writes the difference between dst and src, treating every key of
+// src that dst lacks or holds differently as changed.
+func (s *Service) printDiff() error {
+	changed := make(map[string]string, len(s.src))
+	for k, v := range s.src {
+		if old, ok := s.dst.Data[k]; !ok || !s.valuesEqual(old, v) {
+			changed[k] = v
+		}
+	}
+
+	d := envDiff{jsonPlan: s.buildPlan(changed), Removed: []planValue{}}
+	for _, k := range d.DestOnly {
+		d.Removed = append(d.Removed, planValue{Key: k, Value: s.mask(k, s.dst.Data[k])})
+	}
+
+	switch s.format {
+	case "", "text":
+		var lines []string
+		for _, v := range d.Removed {
+			lines = append(lines, fmt.Sprintf("- %s=%s", v.Key, formatEnvValue(v.Value)))
+		}
+		for _, v := range d.Added {
+			lines = append(lines, fmt.Sprintf("+ %s=%s", v.Key, formatEnvValue(v.Value)))
+		}
+		for _, v := range d.Updated {
+			lines = append(lines, fmt.Sprintf("~ %s: %s -> %s", v.Key, formatEnvValue(v.Old), formatEnvValue(v.New)))
+		}
+
+		return writeTextLines(s.stdout, lines, s.previewLines)
+	case "json":
+		enc := json.NewEncoder(s.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	default:
+		return fmt.Errorf("unknown output format %q (want text or json)", s.format)
+	}
+}
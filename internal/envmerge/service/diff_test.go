@@ -0,0 +1,123 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_printDiff(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		a, b map[string]string
+		want string
+	}{
+		{
+			name: "added removed changed",
+			a:    map[string]string{"OLD": "1", "PORT": "80", "SAME": "x"},
+			b:    map[string]string{"NEW": "a b", "PORT": "8080", "SAME": "x"},
+			want: "- OLD=1\n+ NEW=\"a b\"\n~ PORT: 80 -> 8080\n",
+		},
+		{
+			name: "identical",
+			a:    map[string]string{"A": "1"},
+			b:    map[string]string{"A": "1"},
+			want: "no changes\n",
+		},
+		{
+			name: "secrets masked",
+			a:    map[string]string{"DB_PASSWORD": "old"},
+			b:    map[string]string{"DB_PASSWORD": "new"},
+			want: "~ DB_PASSWORD: *** -> ***\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			secrets, err := parseKeyFilter(DefaultSecretPattern)
+			if err != nil {
+				t.Fatalf("parseKeyFilter: %v", err)
+			}
+
+			var out strings.Builder
+			s := &Service{
+				src:     tc.b,
+				dst:     &field.File{Data: tc.a, Doc: &field.Document{}},
+				secrets: secrets,
+				stdout:  &out,
+			}
+			if err := s.printDiff(); err != nil {
+				t.Fatalf("printDiff: %v", err)
+			}
+			if got := out.String(); got != tc.want {
+				t.Fatalf("got  %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_printDiff_json(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	s := &Service{
+		src:    map[string]string{"B": "2"},
+		dst:    &field.File{Data: map[string]string{"A": "1"}, Doc: &field.Document{}},
+		format: "json",
+		stdout: &out,
+	}
+	if err := s.printDiff(); err != nil {
+		t.Fatalf("printDiff: %v", err)
+	}
+
+	var got envDiff
+	if err := json.Unmarshal([]byte(out.String()), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(got.Removed) != 1 || got.Removed[0] != (planValue{Key: "A", Value: "1"}) {
+		t.Fatalf("removed = %+v", got.Removed)
+	}
+	if len(got.Added) != 1 || got.Added[0] != (planValue{Key: "B", Value: "2"}) {
+		t.Fatalf("added = %+v", got.Added)
+	}
+}
+
+func Test_Diff_files(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	a := filepath.Join(tmpDir, "a.env")
+	b := filepath.Join(tmpDir, "b.env")
+	if err := os.WriteFile(a, []byte("A=1\nB=2\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(b, []byte("B=3\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	var out strings.Builder
+	if err := Diff(config.Config{DiffFiles: []string{a, b}}, &out); err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if got, want := out.String(), "- A=1\n~ B: 2 -> 3\n"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	err := Diff(config.Config{DiffFiles: []string{a, filepath.Join(tmpDir, "missing.env")}}, &out)
+	if !errors.Is(err, field.ErrFileDoesNotExist) {
+		t.Fatalf("expected ErrFileDoesNotExist, got %v", err)
+	}
+	if err := Diff(config.Config{DiffFiles: []string{a}}, &out); err == nil {
+		t.Fatal("expected error for a single file")
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"log/slog"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// Values of --dst-duplicates: which definition of a key the destination
+// repeats is compared against the source.
+const (
+	dstDuplicatesLast  = "last"
+	dstDuplicatesFirst = "first"
+	dstDuplicatesWarn  = "warn"
+)
+
+// duplicateLines returns the lines of every key doc defines more than once,
+// in file order, along with the keys in order of first appearance.
+func duplicateLines(doc *field.Document) ([]string, map[string][]int) {
+	var order []string
+	lines := make(map[string][]int)
+	for _, e := range doc.Entries {
+		if e.Kind != field.KindVar {
+			continue
+		}
+		if _, ok := lines[e.Key]; !ok {
+			order = append(order, e.Key)
+		}
+		lines[e.Key] = append(lines[e.Key], e.Line)
+	}
+
+	var keys []string
+	for _, k := range order {
+		if len(lines[k]) < 2 {
+			delete(lines, k)
+			continue
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, lines
+}
+
+// resolveDuplicates returns the destination values to decide updates
+// against. By default the last definition of a repeated key wins, as for any
+// reader of the file; "first" compares against the first one instead. Both
+// "first" and "warn" log every repeated key so the choice is not silent.
+func resolveDuplicates(path string, doc *field.Document, data map[string]string, policy string) map[string]string {
+	if policy == "" || policy == dstDuplicatesLast {
+		return data
+	}
+
+	keys, lines := duplicateLines(doc)
+	if len(keys) == 0 {
+		return data
+	}
+
+	using := policy
+	if policy == dstDuplicatesWarn {
+		using = dstDuplicatesLast
+	}
+	for _, k := range keys {
+		slog.Default().Warn("destination defines key more than once", "path", path, "key", k, "lines", lines[k], "using", using)
+	}
+	if policy != dstDuplicatesFirst {
+		return data
+	}
+
+	first := make(map[string]string, len(data))
+	for k, v := range data {
+		first[k] = v
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, e := range doc.Entries {
+		if e.Kind == field.KindVar && !seen[e.Key] {
+			seen[e.Key] = true
+			if _, ok := lines[e.Key]; ok {
+				first[e.Key] = e.Value
+			}
+		}
+	}
+
+	return first
+}
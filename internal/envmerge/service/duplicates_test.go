@@ -0,0 +1,75 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+func Test_duplicateLines(t *testing.T) {
+	t.Parallel()
+
+	doc, err := parseDocument(strings.NewReader("B=1\nA=1\nC=1\nA=2\nB=2\nA=3\n"))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+
+	keys, lines := duplicateLines(doc)
+	if !slices.Equal(keys, []string{"B", "A"}) {
+		t.Fatalf("keys = %v, want [B A]", keys)
+	}
+	if !slices.Equal(lines["A"], []int{2, 4, 6}) || !slices.Equal(lines["B"], []int{1, 5}) || lines["C"] != nil {
+		t.Fatalf("lines = %v", lines)
+	}
+}
+
+func Test_Plan_dstDuplicates(t *testing.T) {
+	t.Parallel()
+
+	// The first HOST is the intended one; a later edit added a stale copy.
+	tests := []struct {
+		policy string
+		want   map[string]string
+	}{
+		{policy: "", want: map[string]string{"HOST": "db"}},
+		{policy: dstDuplicatesLast, want: map[string]string{"HOST": "db"}},
+		{policy: dstDuplicatesWarn, want: map[string]string{"HOST": "db"}},
+		{policy: dstDuplicatesFirst, want: map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			srcPath := filepath.Join(tmpDir, ".env.example")
+			dstPath := filepath.Join(tmpDir, ".env")
+			if err := os.WriteFile(srcPath, []byte("HOST=db\nPORT=5432\n"), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if err := os.WriteFile(dstPath, []byte("HOST=db\nPORT=5432\nHOST=localhost\n"), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			s, err := New(config.Config{Src: srcPath, Dst: dstPath, Force: true, DstDuplicates: tt.policy})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if got := s.Plan().Vars; !mapsEqual(got, tt.want) {
+				t.Fatalf("plan = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_configure_dstDuplicates(t *testing.T) {
+	t.Parallel()
+
+	if _, err := configure(config.Config{DstDuplicates: "middle"}, t.TempDir()); err == nil {
+		t.Fatal("an unknown policy should be rejected")
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+const (
+	// encryptedHeader starts every encrypted source. The rest of the file is
+	// the base64 of a 12-byte nonce followed by the AES-256-GCM ciphertext.
+	encryptedHeader = "envmerge-encrypted-v1\n"
+	encryptedExt    = ".enc"
+	// sourceKeyEnv holds the base64 of the 32-byte key for encrypted sources.
+	sourceKeyEnv = "ENVMERGE_SOURCE_KEY"
+)
+
+// decryptSource returns the plaintext of an encrypted source, detected by
+// the .enc extension or the header, together with its name minus .enc so the
+// plaintext's own extension picks the parser. Other sources are returned as
+// they are.
+func decryptSource(name string, r io.Reader) (io.Reader, string, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(len(encryptedHeader))
+
+	hasExt := strings.EqualFold(path.Ext(name), encryptedExt)
+	if !hasExt && string(head) != encryptedHeader {
+		return br, name, nil
+	}
+	if hasExt {
+		name = name[:len(name)-len(encryptedExt)]
+	}
+
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading input: %w", err)
+	}
+
+	plain, err := decrypt(data, os.Getenv(sourceKeyEnv))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return bytes.NewReader(plain), name, nil
+}
+
+// EncryptFile writes path encrypted with the key in ENVMERGE_SOURCE_KEY to
+// path.enc, which decryptSource reads back. path.enc is replaced if it exists.
+func EncryptFile(path string) error {
+	plain, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading file to encrypt: %w", err)
+	}
+
+	data, err := encrypt(plain, os.Getenv(sourceKeyEnv))
+	if err != nil {
+		return err
+	}
+
+	out := path + encryptedExt
+	slog.Default().Info("Writing file", "path", out)
+	if err := os.WriteFile(out, data, 0o644); err != nil {
+		return fmt.Errorf("error writing encrypted file: %w", err)
+	}
+
+	slog.Default().Info("source encrypted", "path", out)
+	return nil
+}
+
+// encrypt seals plain with the base64 key under a random nonce, in the format
+// decrypt opens.
+func encrypt(plain []byte, encodedKey string) ([]byte, error) {
+	gcm, err := sourceCipher(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", field.ErrEncrypt, err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("%w: %v", field.ErrEncrypt, err)
+	}
+	sealed := gcm.Seal(nonce, nonce, plain, nil)
+
+	return []byte(encryptedHeader + base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+// sourceCipher returns the AES-256-GCM cipher for the base64 key.
+func sourceCipher(encodedKey string) (cipher.AEAD, error) {
+	if encodedKey == "" {
+		return nil, fmt.Errorf("%s is not set", sourceKeyEnv)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encodedKey))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must be the base64 of a 32-byte key", sourceKeyEnv)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// decrypt opens an encrypted source with the base64 key. Errors never include
+// the ciphertext or the key.
+func decrypt(data []byte, encodedKey string) ([]byte, error) {
+	gcm, err := sourceCipher(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", field.ErrDecrypt, err)
+	}
+
+	body, ok := bytes.CutPrefix(data, []byte(encryptedHeader))
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %q header", field.ErrDecrypt, strings.TrimSpace(encryptedHeader))
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(body)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: body is not valid base64", field.ErrDecrypt)
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: body is truncated", field.ErrDecrypt)
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: wrong key or corrupted file", field.ErrDecrypt)
+	}
+
+	return plain, nil
+}
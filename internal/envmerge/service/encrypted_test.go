@@ -0,0 +1,126 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func encryptForTest(t *testing.T, key []byte, plain string) string {
+	t.Helper()
+
+	data, err := encrypt([]byte(plain), base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	return string(data)
+}
+
+func Test_decrypt(t *testing.T) {
+	t.Parallel()
+
+	key := bytes.Repeat([]byte{7}, 32)
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+	data := encryptForTest(t, key, "API_TOKEN=s3cret\n")
+
+	plain, err := decrypt([]byte(data), encodedKey)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if string(plain) != "API_TOKEN=s3cret\n" {
+		t.Fatalf("got %q", plain)
+	}
+
+	cases := []struct {
+		name string
+		data string
+		key  string
+	}{
+		{name: "no key", data: data},
+		{name: "short key", data: data, key: base64.StdEncoding.EncodeToString(key[:16])},
+		{name: "wrong key", data: data, key: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{8}, 32))},
+		{name: "no header", data: strings.TrimPrefix(data, encryptedHeader), key: encodedKey},
+		{name: "corrupted", data: encryptedHeader + "AAAA" + data[len(encryptedHeader)+4:], key: encodedKey},
+		{name: "truncated", data: encryptedHeader + "AAAA\n", key: encodedKey},
+	}
+	for _, tc := range cases {
+		_, err := decrypt([]byte(tc.data), tc.key)
+		if !errors.Is(err, field.ErrDecrypt) {
+			t.Fatalf("%s: expected ErrDecrypt, got %v", tc.name, err)
+		}
+		if strings.Contains(err.Error(), strings.TrimSpace(tc.data[len(encryptedHeader):])) {
+			t.Fatalf("%s: error leaks the ciphertext: %v", tc.name, err)
+		}
+	}
+}
+
+func Test_sourceDocument_encrypted(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	t.Setenv(sourceKeyEnv, base64.StdEncoding.EncodeToString(key))
+
+	cases := []struct {
+		name  string
+		plain string
+		want  map[string]string
+	}{
+		{name: ".env.enc", plain: "A=1\n", want: map[string]string{"A": "1"}},
+		{name: "env.json.enc", plain: `{"DB": {"HOST": "h"}}`, want: map[string]string{"DB__HOST": "h"}},
+		{name: ".env.example", plain: "B=2\n", want: map[string]string{"B": "2"}},
+	}
+	for _, tc := range cases {
+		doc, err := parser{}.sourceDocument(tc.name, strings.NewReader(encryptForTest(t, key, tc.plain)))
+		if err != nil {
+			t.Fatalf("%s: sourceDocument: %v", tc.name, err)
+		}
+		if got := doc.Map(); !mapsEqual(got, tc.want) {
+			t.Fatalf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	doc, err := parser{}.sourceDocument(".env", strings.NewReader("PLAIN=1\n"))
+	if err != nil {
+		t.Fatalf("plaintext: %v", err)
+	}
+	if got := doc.Map(); !mapsEqual(got, map[string]string{"PLAIN": "1"}) {
+		t.Fatalf("plaintext: got %v", got)
+	}
+}
+
+func Test_EncryptFile(t *testing.T) {
+	key := bytes.Repeat([]byte{3}, 32)
+	t.Setenv(sourceKeyEnv, base64.StdEncoding.EncodeToString(key))
+
+	tmpDir := t.TempDir()
+	plainPath := filepath.Join(tmpDir, ".env.example")
+	if err := os.WriteFile(plainPath, []byte("API_TOKEN=s3cret\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if err := EncryptFile(plainPath); err != nil {
+		t.Fatalf("EncryptFile: %v", err)
+	}
+	data := mustReadFile(t, plainPath+encryptedExt)
+	if !strings.HasPrefix(data, encryptedHeader) || strings.Contains(data, "s3cret") {
+		t.Fatalf("not encrypted: %q", data)
+	}
+
+	doc, err := readSrcFile(tmpDir, ".env.example.enc", parser{})
+	if err != nil {
+		t.Fatalf("readSrcFile: %v", err)
+	}
+	if got := doc.Map(); !mapsEqual(got, map[string]string{"API_TOKEN": "s3cret"}) {
+		t.Fatalf("got %v", got)
+	}
+
+	t.Setenv(sourceKeyEnv, "")
+	if err := EncryptFile(plainPath); !errors.Is(err, field.ErrEncrypt) {
+		t.Fatalf("expected ErrEncrypt without a key, got %v", err)
+	}
+}
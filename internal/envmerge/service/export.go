@@ -0,0 +1,38 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+const exportPrefix = "export "
+
+// keyPrefix is what goes before key on a written line: the source's export
+// prefix, unless stripExport is set.
+func (s *Service) keyPrefix(key string) string {
+	if s.stripExport || !s.srcEntries[key].Export {
+		return ""
+	}
+
+	return exportPrefix
+}
+
+// stripExports removes the export prefix from every var in doc and reports
+// whether any line changed.
+func stripExports(doc *field.Document) bool {
+	changed := false
+	for i, e := range doc.Entries {
+		if e.Kind != field.KindVar || !e.Export {
+			continue
+		}
+
+		indent := e.Raw[:len(e.Raw)-len(strings.TrimLeft(e.Raw, " \t"))]
+		rest := strings.TrimLeft(strings.TrimPrefix(e.Raw[len(indent):], "export"), " \t")
+		doc.Entries[i].Raw = indent + rest
+		doc.Entries[i].Export = false
+		changed = true
+	}
+
+	return changed
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+func Test_parseDocument_exportPrefix(t *testing.T) {
+	t.Parallel()
+
+	doc, err := parseDocument(strings.NewReader("export A=1\nexport\tB=\"two words\"\nexporter=3\nC=4\n"))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+
+	if got := doc.Map(); !mapsEqual(got, map[string]string{"A": "1", "B": "two words", "exporter": "3", "C": "4"}) {
+		t.Fatalf("got %#v", got)
+	}
+
+	vars := doc.Vars()
+	if !vars["A"].Export || !vars["B"].Export || vars["exporter"].Export || vars["C"].Export {
+		t.Fatalf("unexpected export flags: %#v", vars)
+	}
+}
+
+func Test_Run_shebangExportFile(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, ".env.example")
+	dstPath := filepath.Join(tmpDir, ".env")
+	for path, content := range map[string]string{
+		srcPath: "#!/usr/bin/env sh\n# the greeting\nexport GREETING=\"hello world\"\nexport DB_URL=\"postgres://db/app\"\nPLAIN=1\n",
+		dstPath: "#!/usr/bin/env sh\nexport DB_URL=\"postgres://db/app\"\n",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	s, err := New(config.Config{Src: srcPath, Dst: dstPath, CarryComments: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	got := mustReadFile(t, dstPath)
+	if !strings.HasPrefix(got, "#!/usr/bin/env sh\n") || strings.Count(got, "#!") != 1 {
+		t.Fatalf("the shebang should stay the only first line, got %q", got)
+	}
+	if !strings.Contains(got, "# the greeting\nexport GREETING=\"hello world\"\nPLAIN=1\n") {
+		t.Fatalf("unexpected sync block: %q", got)
+	}
+
+	doc, err := parseDocument(strings.NewReader(got))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+	if want := map[string]string{"DB_URL": "postgres://db/app", "GREETING": "hello world", "PLAIN": "1"}; !mapsEqual(doc.Map(), want) {
+		t.Fatalf("got %v, want %v", doc.Map(), want)
+	}
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	out, err := exec.Command(sh, "-c", `. "$1" && sh -c 'printf "%s|%s|%s" "$DB_URL" "$GREETING" "$PLAIN"'`, "sh", dstPath).Output()
+	if err != nil {
+		t.Fatalf("sourcing the destination: %v", err)
+	}
+	// PLAIN is not exported in the source, so a child shell does not see it.
+	if string(out) != "postgres://db/app|hello world|" {
+		t.Fatalf("sourced env = %q", out)
+	}
+}
+
+func Test_Run_stripExport(t *testing.T) {
+	t.Parallel()
+
+	srcDoc, err := parseDocument(strings.NewReader("export A=1\nexport NEW=2\n"))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		strip bool
+		want  string
+	}{
+		{name: "kept", want: "export A=1\n\n# envmerge sync run: <ts>\nexport NEW=2\n"},
+		{name: "stripped", strip: true, want: "A=1\n\n# envmerge sync run: <ts>\nNEW=2\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			dstPath := filepath.Join(tmpDir, ".env")
+			if err := os.WriteFile(dstPath, []byte("export A=1\n"), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			dst, err := readDstFile(tmpDir, ".env", parser{})
+			if err != nil {
+				t.Fatalf("readDstFile: %v", err)
+			}
+
+			s := &Service{
+				stripExport: tc.strip,
+				src:         srcDoc.Map(),
+				srcEntries:  srcDoc.Vars(),
+				dst:         dst,
+			}
+			if err := s.Run(); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			lines := strings.Split(mustReadFile(t, dstPath), "\n")
+			for i, l := range lines {
+				if strings.HasPrefix(l, "# envmerge sync run: ") {
+					lines[i] = "# envmerge sync run: <ts>"
+				}
+			}
+			if got := strings.Join(lines, "\n"); got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_stripExports(t *testing.T) {
+	t.Parallel()
+
+	doc, err := parseDocument(strings.NewReader("  export A=1\r\n# export B=2\nC=3\n"))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+
+	if !stripExports(doc) {
+		t.Fatal("expected a change")
+	}
+	if got := doc.String(); got != "  A=1\r\n# export B=2\nC=3\n" {
+		t.Fatalf("got %q", got)
+	}
+	if stripExports(doc) {
+		t.Fatal("second strip should be a no-op")
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// fileRefPrefix marks an unquoted value such as @certs/server.pem as a
+// reference to a file whose contents become the value. Quote the value to
+// keep a literal leading @.
+const fileRefPrefix = "@"
+
+// expandFileRefs replaces file references in doc with the referenced
+// contents. name is the file doc was read from, and references resolve
+// relative to it: inside fsys when set, else on the OS filesystem under dir.
+func expandFileRefs(doc *field.Document, dir, name string, fsys fs.FS) error {
+	for i, e := range doc.Entries {
+		if e.Kind != field.KindVar || e.Quoted || !strings.HasPrefix(e.Value, fileRefPrefix) {
+			continue
+		}
+
+		ref := strings.TrimPrefix(e.Value, fileRefPrefix)
+
+		var (
+			data []byte
+			err  error
+		)
+		if fsys != nil {
+			data, err = fs.ReadFile(fsys, path.Join(path.Dir(name), ref))
+		} else {
+			data, err = os.ReadFile(resolvePath(filepath.Dir(resolvePath(dir, name)), ref))
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("key %q references %q: %w", e.Key, ref, field.ErrFileDoesNotExist)
+		}
+		if err != nil {
+			return fmt.Errorf("key %q references %q: %w", e.Key, ref, err)
+		}
+
+		doc.Entries[i].Value = string(data)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_readSources_expandFileRefs(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"conf/.env.example":     &fstest.MapFile{Data: []byte("TLS_CERT=@certs/server.pem\nLITERAL=\"@handle\"\nPLAIN=x\n")},
+		"conf/certs/server.pem": &fstest.MapFile{Data: []byte("-----BEGIN CERT-----\nabc\n-----END CERT-----\n")},
+	}
+
+	got, err := readSources("", fsys, config.Config{Src: "conf/.env.example", ExpandFileRefs: true}, parser{})
+	if err != nil {
+		t.Fatalf("readSources: %v", err)
+	}
+
+	want := map[string]string{
+		"TLS_CERT": "-----BEGIN CERT-----\nabc\n-----END CERT-----\n",
+		"LITERAL":  "@handle",
+		"PLAIN":    "x",
+	}
+	if !mapsEqual(got.Map(), want) {
+		t.Fatalf("got %#v, want %#v", got.Map(), want)
+	}
+
+	got, err = readSources("", fsys, config.Config{Src: "conf/.env.example"}, parser{})
+	if err != nil {
+		t.Fatalf("readSources: %v", err)
+	}
+	if got.Map()["TLS_CERT"] != "@certs/server.pem" {
+		t.Fatalf("references must stay literal without the flag: %#v", got.Map())
+	}
+}
+
+func Test_readSources_expandFileRefs_osRelativeToSource(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "conf"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "conf", ".env.example"), []byte("KEY=@key.txt\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "conf", "key.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	got, err := readSources(dir, nil, config.Config{Src: "conf/.env.example", ExpandFileRefs: true}, parser{})
+	if err != nil {
+		t.Fatalf("readSources: %v", err)
+	}
+	if got.Map()["KEY"] != "secret" {
+		t.Fatalf("got %#v", got.Map())
+	}
+}
+
+func Test_readSources_expandFileRefs_missing(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		".env.example": &fstest.MapFile{Data: []byte("TLS_CERT=@certs/missing.pem\n")},
+	}
+
+	_, err := readSources("", fsys, config.Config{Src: ".env.example", ExpandFileRefs: true}, parser{})
+	if !errors.Is(err, field.ErrFileDoesNotExist) {
+		t.Fatalf("expected ErrFileDoesNotExist, got %v", err)
+	}
+}
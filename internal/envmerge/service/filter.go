@@ -0,0 +1,51 @@
+package service
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+type filterRule struct {
+	pattern string
+	negate  bool
+}
+
+// keyFilter is an ordered chain of glob rules. Every matching rule overrides
+// the previous decision: plain patterns exclude, patterns with a leading "!"
+// re-include. Keys that match no rule are included.
+type keyFilter []filterRule
+
+func parseKeyFilter(spec string) (keyFilter, error) {
+	var f keyFilter
+	for _, raw := range strings.Split(spec, ",") {
+		pattern := strings.TrimSpace(raw)
+		if pattern == "" {
+			continue
+		}
+
+		rule := filterRule{pattern: pattern}
+		if strings.HasPrefix(pattern, "!") {
+			rule = filterRule{pattern: strings.TrimPrefix(pattern, "!"), negate: true}
+		}
+
+		if _, err := path.Match(rule.pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+
+		f = append(f, rule)
+	}
+
+	return f, nil
+}
+
+func (f keyFilter) included(key string) bool {
+	included := true
+	for _, r := range f {
+		if ok, _ := path.Match(r.pattern, key); ok {
+			included = r.negate
+		}
+	}
+
+	return included
+}
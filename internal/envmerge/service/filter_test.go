@@ -0,0 +1,101 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_keyFilter_included(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		spec string
+		keys map[string]bool
+	}{
+		{
+			name: "empty spec includes everything",
+			spec: "",
+			keys: map[string]bool{"A": true, "SECRET_X": true},
+		},
+		{
+			name: "exclude then re-include",
+			spec: "SECRET_*,!SECRET_PUBLIC_*",
+			keys: map[string]bool{
+				"SECRET_KEY":        false,
+				"SECRET_PUBLIC_KEY": true,
+				"PORT":              true,
+			},
+		},
+		{
+			name: "later rule wins",
+			spec: "!SECRET_PUBLIC_*,SECRET_*",
+			keys: map[string]bool{
+				"SECRET_KEY":        false,
+				"SECRET_PUBLIC_KEY": false,
+				"PORT":              true,
+			},
+		},
+		{
+			name: "multiple patterns with spaces",
+			spec: " DB_* , CACHE_?? ",
+			keys: map[string]bool{"DB_HOST": false, "CACHE_TT": false, "CACHE_TTL": true},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f, err := parseKeyFilter(tc.spec)
+			if err != nil {
+				t.Fatalf("parseKeyFilter: %v", err)
+			}
+
+			for key, want := range tc.keys {
+				if got := f.included(key); got != want {
+					t.Fatalf("included(%q)=%v; want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_parseKeyFilter_invalidPattern(t *testing.T) {
+	t.Parallel()
+
+	if _, err := parseKeyFilter("A,[bad"); err == nil {
+		t.Fatalf("expected error for malformed pattern")
+	}
+}
+
+func Test_exclude_appliedToNewVarsAndUpdates(t *testing.T) {
+	t.Parallel()
+
+	exclude, err := parseKeyFilter("SECRET_*,!SECRET_PUBLIC_*")
+	if err != nil {
+		t.Fatalf("parseKeyFilter: %v", err)
+	}
+
+	s := &Service{
+		exclude: exclude,
+		src: map[string]string{
+			"SECRET_KEY":        "s",
+			"SECRET_PUBLIC_KEY": "p",
+			"PORT":              "8080",
+		},
+		dst: &field.File{Data: map[string]string{"PORT": "80"}},
+	}
+
+	got := s.determineNewVars()
+	if !mapsEqual(got, map[string]string{"SECRET_PUBLIC_KEY": "p"}) {
+		t.Fatalf("determineNewVars=%#v", got)
+	}
+
+	s.force = true
+	got = s.determineUpdates()
+	if !mapsEqual(got, map[string]string{"SECRET_PUBLIC_KEY": "p", "PORT": "8080"}) {
+		t.Fatalf("determineUpdates=%#v", got)
+	}
+}
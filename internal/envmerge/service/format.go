@@ -0,0 +1,126 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// Format rewrites the destination into canonical form: values are quoted by
+// formatValue, so only when they need it, comments and keys are stripped of
+// surrounding whitespace, inline comments after values are kept, and runs of
+// blank lines are collapsed. Keys keep their source order so comments stay
+// next to the keys they describe. The file is left untouched when already
+// canonical.
+func (s *Service) Format() error {
+	defer func() {
+		if s.dst != nil && s.dst.Dsc != nil {
+			_ = s.dst.Dsc.Close()
+		}
+	}()
+
+	original := s.dst.Doc.String()
+	if s.stripExport {
+		stripExports(s.dst.Doc)
+	}
+
+	formatted := s.formatDocument(s.dst.Doc)
+	if formatted == original {
+		slog.Default().Info("dotenv already formatted")
+		return nil
+	}
+
+	if err := s.rewrite(formatted); err != nil {
+		return fmt.Errorf("error writing formatted destination: %w", err)
+	}
+
+	slog.Default().Info("dotenv formatted")
+	return nil
+}
+
+func (s *Service) formatDocument(doc *field.Document) string {
+	var (
+		b            strings.Builder
+		pendingBlank bool
+	)
+
+	for _, e := range doc.Entries {
+		if e.Kind == field.KindBlank {
+			pendingBlank = b.Len() > 0
+			continue
+		}
+
+		if pendingBlank {
+			b.WriteString("\n")
+			pendingBlank = false
+		}
+
+		switch e.Kind {
+		case field.KindComment:
+			b.WriteString(strings.TrimSpace(e.Raw) + "\n")
+		case field.KindVar:
+			v, comment := splitInlineComment(e, e.Value)
+			value := s.formatValue(v)
+			// Unquoted, the comment would be read back as part of the value.
+			if comment != "" && e.Quoted && !strings.HasPrefix(value, `"`) {
+				value = quoteEnvValue(v)
+			}
+			prefix := ""
+			if e.Export {
+				prefix = exportPrefix
+			}
+			b.WriteString(fmt.Sprintf("%s%s=%s%s\n", prefix, e.Key, value, comment))
+		}
+	}
+
+	return b.String()
+}
+
+// splitInlineComment returns value, about to be written for e, and the
+// comment that followed e's value on its last line, with a space before it,
+// so re-rendering e keeps it. Only a quoted value can be followed by any
+// comment; after an unquoted one, the rest of the line belongs to the value
+// except a "# type:" hint. The parser cuts that off when it reads type hints;
+// otherwise it is still part of value and moved out of it, so a replacement
+// value without it drops the hint as it would drop any old text.
+func splitInlineComment(e field.Entry, value string) (string, string) {
+	raw := strings.TrimRight(e.Raw, "\r\n")
+
+	if e.Quoted {
+		line := raw
+		if i := strings.LastIndex(raw, "\n"); i >= 0 {
+			line = raw[i+1:]
+		} else {
+			_, rest, _ := strings.Cut(raw, "=")
+			line = strings.TrimPrefix(strings.TrimLeft(rest, " \t"), `"`)
+		}
+
+		end := closingQuoteIndex(line)
+		if end < 0 {
+			return value, ""
+		}
+		if comment := strings.TrimSpace(line[end+1:]); comment != "" {
+			return value, " " + comment
+		}
+
+		return value, ""
+	}
+
+	if e.Type == "" {
+		v, hint := cutTypeHint(value)
+		if hint == "" {
+			return value, ""
+		}
+		return v, " " + strings.TrimSpace(value[len(v):])
+	}
+
+	_, rest, _ := strings.Cut(raw, "=")
+	m := typeHintPattern.FindStringIndex(rest)
+	if m == nil {
+		return value, ""
+	}
+
+	return value, " " + strings.TrimSpace(rest[m[0]:])
+}
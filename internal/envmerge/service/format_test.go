@@ -0,0 +1,134 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_formatDocument(t *testing.T) {
+	t.Parallel()
+
+	in := "\n\n  # database   \nDB_HOST =  localhost  \n\n\n\nGREETING=\"hello world\"\nPLAIN=\"abc\"\nBARE=abc\nexport  SHELL_KEY=1\nKEY=\"line1\nline2\"\n\n" +
+		"NOTE=\"x\"   # a note  \nPORT=80 # type:int\nML=\"a\nb\"\t# end\n"
+	want := "# database\nDB_HOST=localhost\n\nGREETING=\"hello world\"\nPLAIN=abc\nBARE=abc\nexport SHELL_KEY=1\nKEY=\"line1\nline2\"\n" +
+		"\nNOTE=\"x\" # a note\nPORT=80 # type:int\nML=\"a\nb\" # end\n"
+
+	doc, err := parseDocument(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+
+	got := (&Service{}).formatDocument(doc)
+	if got != want {
+		t.Fatalf("formatDocument mismatch\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func Test_Format_idempotent(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	dstPath := filepath.Join(tmpDir, ".env")
+
+	in := "# app\nPORT = 8080   \n\n\n# envmerge sync run: 2024-01-01 00:00:00\nNAME=\"my app\"\t\n"
+	if err := os.WriteFile(dstPath, []byte(in), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	format := func() string {
+		t.Helper()
+
+		f, err := readDstFile(tmpDir, ".env", parser{})
+		if err != nil {
+			t.Fatalf("readDstFile: %v", err)
+		}
+
+		s := &Service{dst: f}
+		if err := s.Format(); err != nil {
+			t.Fatalf("Format: %v", err)
+		}
+
+		return mustReadFile(t, dstPath)
+	}
+
+	first := format()
+	if first == in {
+		t.Fatalf("expected first run to rewrite the file")
+	}
+
+	second := format()
+	if second != first {
+		t.Fatalf("second run is not a no-op\nfirst:  %q\nsecond: %q", first, second)
+	}
+}
+
+func Test_parseDocument_quoted(t *testing.T) {
+	t.Parallel()
+
+	doc, err := parseDocument(strings.NewReader("A=\"1\"\nB=1\nC=\"x\ny\"\n"))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+
+	want := map[string]bool{"A": true, "B": false, "C": true}
+	for _, e := range doc.Entries {
+		if e.Quoted != want[e.Key] {
+			t.Fatalf("%s: Quoted=%v; want %v", e.Key, e.Quoted, want[e.Key])
+		}
+	}
+
+	if m := doc.Map(); m["A"] != m["B"] {
+		t.Fatalf("plain map should not distinguish quoting: %#v", m)
+	}
+}
+
+func Test_parseDocument_rawRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	in := "# c\r\nA=1\r\n\r\nKEY=\"line1\nline2\"  \nB = 2"
+
+	doc, err := parseDocument(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+
+	if got := doc.String(); got != in {
+		t.Fatalf("raw round trip mismatch\ngot:  %q\nwant: %q", got, in)
+	}
+}
+
+func Test_splitInlineComment(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		p       parser
+		line    string
+		value   string
+		want    string
+		comment string
+	}{
+		{name: "quoted", line: "A=\"x\"  # note\n", value: "y", want: "y", comment: " # note"},
+		{name: "quoted with quote in comment", line: "A=\"x\" # say \"hi\"\n", value: "y", want: "y", comment: " # say \"hi\""},
+		{name: "multiline", line: "A=\"x\ny\" # note\n", value: "z", want: "z", comment: " # note"},
+		{name: "plain", line: "A=x\n", value: "y", want: "y"},
+		{name: "unquoted hash is value", line: "A=x # note\n", value: "x # note", want: "x # note"},
+		{name: "hint in value", line: "A=1 # type:int\n", value: "1 # type:int", want: "1", comment: " # type:int"},
+		{name: "hint replaced", line: "A=1 # type:int\n", value: "2", want: "2"},
+		{name: "hint cut by parser", p: parser{typeHints: true}, line: "A=1 # type:int\n", value: "2", want: "2", comment: " # type:int"},
+	}
+
+	for _, tc := range cases {
+		doc, err := tc.p.document(strings.NewReader(tc.line))
+		if err != nil {
+			t.Fatalf("%s: parse: %v", tc.name, err)
+		}
+
+		got, comment := splitInlineComment(doc.Entries[0], tc.value)
+		if got != tc.want || comment != tc.comment {
+			t.Errorf("%s: got (%q, %q), want (%q, %q)", tc.name, got, comment, tc.want, tc.comment)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// githubOutputEnv names the file GitHub Actions reads step outputs from.
+const githubOutputEnv = "GITHUB_OUTPUT"
+
+// writeGitHubOutput appends the run's added and updated counts, and whether
+// anything changed, to the GitHub Actions step output file. A rewrite that
+// changed the destination without touching a key, like --strip-export,
+// counts as a change.
+func (s *Service) writeGitHubOutput(plan Plan) error {
+	r := s.buildReport(plan, time.Now())
+	return appendGitHubOutput(s.githubOutput, len(r.Added), len(r.Updated), s.dstRewritten)
+}
+
+// appendGitHubOutput appends the step outputs for a run to path; changed is
+// also true when keys were added or updated.
+func appendGitHubOutput(path string, added, updated int, changed bool) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open %q: %w", path, err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "added=%d\nupdated=%d\nchanged=%t\n", added, updated, changed || added+updated > 0)
+	return err
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func Test_Run_githubOutput(t *testing.T) {
+	t.Parallel()
+
+	dstPath := writeTempFile(t, "A=1\nB=old\n")
+	dst, err := readDstFile(filepath.Dir(dstPath), filepath.Base(dstPath), parser{})
+	if err != nil {
+		t.Fatalf("readDstFile: %v", err)
+	}
+
+	output := filepath.Join(t.TempDir(), "github_output")
+	s := &Service{
+		force:        true,
+		githubOutput: output,
+		src:          map[string]string{"A": "1", "B": "new", "C": "3", "D": "4"},
+		dst:          dst,
+	}
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// A second run has nothing to do; outputs are appended after the first.
+	s.dst, err = readDstFile(filepath.Dir(dstPath), filepath.Base(dstPath), parser{})
+	if err != nil {
+		t.Fatalf("readDstFile: %v", err)
+	}
+	if err := s.Run(); err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+
+	want := "added=2\nupdated=1\nchanged=true\n" + "added=0\nupdated=0\nchanged=false\n"
+	if got := mustReadFile(t, output); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func Test_Run_githubOutputRewriteOnly(t *testing.T) {
+	t.Parallel()
+
+	dstPath := writeTempFile(t, "export A=1\n")
+	dst, err := readDstFile(filepath.Dir(dstPath), filepath.Base(dstPath), parser{})
+	if err != nil {
+		t.Fatalf("readDstFile: %v", err)
+	}
+
+	output := filepath.Join(t.TempDir(), "github_output")
+	s := &Service{
+		stripExport:  true,
+		githubOutput: output,
+		src:          map[string]string{"A": "1"},
+		dst:          dst,
+	}
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if got := mustReadFile(t, dstPath); got != "A=1\n" {
+		t.Fatalf("destination = %q, want the export prefix stripped", got)
+	}
+	if got, want := mustReadFile(t, output), "added=0\nupdated=0\nchanged=true\n"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
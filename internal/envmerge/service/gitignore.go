@@ -0,0 +1,50 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ensureGitignore lists the file at path in the .gitignore next to it,
+// creating the .gitignore if needed. Nothing is written when the file is
+// already listed, as name or /name.
+func ensureGitignore(path string) error {
+	name := filepath.Base(path)
+	ignorePath := filepath.Join(filepath.Dir(path), ".gitignore")
+
+	data, err := os.ReadFile(ignorePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read %q: %w", ignorePath, err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		switch strings.TrimSpace(line) {
+		case name, "/" + name:
+			return nil
+		}
+	}
+
+	entry := name + "\n"
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		entry = "\n" + entry
+	}
+
+	f, err := os.OpenFile(ignorePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open %q: %w", ignorePath, err)
+	}
+	if _, err := f.WriteString(entry); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write %q: %w", ignorePath, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close %q: %w", ignorePath, err)
+	}
+
+	slog.Default().Info("Added to .gitignore", "path", ignorePath, "entry", name)
+	return nil
+}
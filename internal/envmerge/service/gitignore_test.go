@@ -0,0 +1,52 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_ensureGitignore(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		existing *string
+		want     string
+	}{
+		{name: "created", want: ".env\n"},
+		{name: "appended", existing: ptr("node_modules/\n"), want: "node_modules/\n.env\n"},
+		{name: "appended without trailing newline", existing: ptr("bin"), want: "bin\n.env\n"},
+		{name: "already listed", existing: ptr("bin\n.env\n"), want: "bin\n.env\n"},
+		{name: "already listed rooted", existing: ptr("/.env\r\n"), want: "/.env\r\n"},
+		{name: "pattern is not a listing", existing: ptr(".env.*\n"), want: ".env.*\n.env\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			ignorePath := filepath.Join(tmpDir, ".gitignore")
+			if tc.existing != nil {
+				if err := os.WriteFile(ignorePath, []byte(*tc.existing), 0o644); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+			}
+
+			for range 2 {
+				if err := ensureGitignore(filepath.Join(tmpDir, ".env")); err != nil {
+					t.Fatalf("ensureGitignore: %v", err)
+				}
+			}
+
+			if got := mustReadFile(t, ignorePath); got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// gitSourcePrefix marks a --src read from git, as git:REF:PATH.
+const gitSourcePrefix = "git:"
+
+// readGitSrcFile reads a git:REF:PATH source with "git show REF:PATH", run in
+// dir, so the committed file is used whatever the working tree holds. PATH
+// follows git's rules: relative to the repository root, or to dir when it
+// starts with "./".
+func readGitSrcFile(dir, spec string, p parser) (*field.Document, error) {
+	ref, path, ok := strings.Cut(strings.TrimPrefix(spec, gitSourcePrefix), ":")
+	if !ok || ref == "" || path == "" {
+		return nil, fmt.Errorf("invalid git source %q (want git:REF:PATH)", spec)
+	}
+	// git would take such a ref for an option, like --output=FILE.
+	if strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("invalid git source %q: ref must not start with \"-\"", spec)
+	}
+	slog.Default().Info("Reading file", "path", path, "ref", ref)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "show", ref+":"+path)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("git show %s:%s: %s", ref, path, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("git show %s:%s: %w", ref, path, err)
+	}
+
+	doc, err := p.sourceDocument(path, &stdout)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %q at %s: %w", path, ref, err)
+	}
+
+	return doc, nil
+}
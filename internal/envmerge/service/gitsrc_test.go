@@ -0,0 +1,70 @@
+package service
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_readSrcFile_git(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+
+		if err := os.WriteFile(filepath.Join(dir, ".env.example"), []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	git("init", "-q")
+	write("A=committed\n")
+	git("add", ".env.example")
+	git("commit", "-q", "-m", "example")
+	write("A=local\nB=2\n")
+
+	doc, err := readSrcFile(dir, "git:HEAD:.env.example", parser{})
+	if err != nil {
+		t.Fatalf("readSrcFile: %v", err)
+	}
+	if got := doc.Map(); !mapsEqual(got, map[string]string{"A": "committed"}) {
+		t.Fatalf("got %v", got)
+	}
+
+	local, err := readSrcFile(dir, ".env.example", parser{})
+	if err != nil {
+		t.Fatalf("readSrcFile local: %v", err)
+	}
+	if got := local.Map(); !mapsEqual(got, map[string]string{"A": "local", "B": "2"}) {
+		t.Fatalf("local: got %v", got)
+	}
+
+	for _, spec := range []string{"git:HEAD", "git::.env.example", "git:HEAD:", "git:nosuchref:.env.example", "git:HEAD:missing.env", "git:--output=out:.env.example"} {
+		_, err := readSrcFile(dir, spec, parser{})
+		if err == nil {
+			t.Fatalf("%s: expected an error", spec)
+		}
+		if !strings.Contains(err.Error(), "git") {
+			t.Fatalf("%s: unclear error %v", spec, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out:.env.example")); err == nil {
+		t.Fatal("a ref starting with - was passed to git as an option")
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// ignoreAnnotation in the destination keeps envmerge from ever adding or
+// updating a key: directly above the key's line, or anywhere followed by a
+// comma-separated list of keys, which also covers keys the file lacks.
+const ignoreAnnotation = "# envmerge:ignore"
+
+// ignoredKeys collects the keys doc pins out of automation.
+func ignoredKeys(doc *field.Document) map[string]struct{} {
+	ignored := make(map[string]struct{})
+	for _, e := range doc.Entries {
+		switch e.Kind {
+		case field.KindVar:
+			for _, c := range e.Doc {
+				if c == ignoreAnnotation {
+					ignored[e.Key] = struct{}{}
+				}
+			}
+		case field.KindComment:
+			rest, ok := strings.CutPrefix(strings.TrimSpace(e.Raw), ignoreAnnotation)
+			if !ok || rest == "" || (rest[0] != ' ' && rest[0] != '\t') {
+				continue
+			}
+			for _, k := range strings.Split(rest, ",") {
+				if k = strings.TrimSpace(k); k != "" {
+					ignored[k] = struct{}{}
+				}
+			}
+		}
+	}
+
+	return ignored
+}
+
+// isIgnored reports whether the destination pins key out of automation.
+func (s *Service) isIgnored(key string) bool {
+	_, ok := s.ignored[key]
+	return ok
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_ignoredKeys(t *testing.T) {
+	t.Parallel()
+
+	doc, err := parseDocument(strings.NewReader(
+		"# envmerge:ignore\nPINNED=local\n" +
+			"# envmerge:ignore MISSING, OTHER\n\n" +
+			"# envmerge:ignored is not the annotation\nNOT_PINNED=1\n"))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+
+	got := ignoredKeys(doc)
+	want := map[string]struct{}{"PINNED": {}, "MISSING": {}, "OTHER": {}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k := range want {
+		if _, ok := got[k]; !ok {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func Test_Plan_ignored(t *testing.T) {
+	t.Parallel()
+
+	doc, err := parseDocument(strings.NewReader("# envmerge:ignore\nPINNED=local\n# envmerge:ignore MISSING\nOTHER=old\n"))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+
+	for _, force := range []bool{false, true} {
+		s := &Service{
+			force:   force,
+			src:     map[string]string{"PINNED": "src", "MISSING": "src", "OTHER": "new", "NEW": "1"},
+			dst:     &field.File{Data: doc.Map(), Doc: doc},
+			ignored: ignoredKeys(doc),
+		}
+
+		want := map[string]string{"NEW": "1"}
+		if force {
+			want["OTHER"] = "new"
+		}
+		if got := s.Plan().Vars; !mapsEqual(got, want) {
+			t.Fatalf("force=%v: plan = %v, want %v", force, got, want)
+		}
+	}
+}
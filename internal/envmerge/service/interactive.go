@@ -0,0 +1,83 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// Values of --interactive-fallback, used when stdin is not a terminal.
+const (
+	interactiveFallbackNone = "none"
+	interactiveFallbackAll  = "all"
+)
+
+// confirmPlan asks about every change of plan in turn and returns the plan
+// reduced to the approved ones: y applies a change, n or Enter skips it, a
+// applies it and all that follow, and q aborts the run. Secrets are masked
+// in the prompts. Without a terminal nothing is asked, and the fallback
+// decides whether all or none of plan is applied.
+func (s *Service) confirmPlan(plan Plan) (Plan, error) {
+	if len(plan.Vars) == 0 {
+		return plan, nil
+	}
+	if !s.stdinIsTerminal {
+		if s.interactiveFallback == interactiveFallbackAll {
+			slog.Default().Warn("stdin is not a terminal, applying every change")
+			return plan, nil
+		}
+		slog.Default().Warn("stdin is not a terminal, applying no changes")
+		return Plan{Vars: map[string]string{}, Force: plan.Force}, nil
+	}
+
+	w := s.stdout
+	if w == nil {
+		w = os.Stdout
+	}
+	in := bufio.NewReader(s.stdin)
+
+	approved := make(map[string]string, len(plan.Vars))
+	all := false
+	for _, k := range sortedKeys(plan.Vars) {
+		v := plan.Vars[k]
+		if all {
+			approved[k] = v
+			continue
+		}
+
+		change := fmt.Sprintf("%s=%s", k, formatEnvValue(s.mask(k, v)))
+		if old, ok := s.dst.Data[k]; ok {
+			change = fmt.Sprintf("%s: %s -> %s", k, formatEnvValue(s.mask(k, old)), formatEnvValue(s.mask(k, v)))
+		}
+		if _, err := fmt.Fprintf(w, "apply %s? [y/N/a/q] ", change); err != nil {
+			return Plan{}, err
+		}
+
+		answer, err := in.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return Plan{}, fmt.Errorf("error reading answer: %w", err)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			approved[k] = v
+		case "a", "all":
+			approved[k] = v
+			all = true
+		case "q", "quit":
+			return Plan{}, field.ErrAborted
+		}
+		if errors.Is(err, io.EOF) && !all {
+			// No more answers: skip the rest.
+			break
+		}
+	}
+
+	return Plan{Vars: approved, Force: plan.Force}, nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_confirmPlan(t *testing.T) {
+	t.Parallel()
+
+	plan := Plan{Vars: map[string]string{"A": "1", "B": "new", "C": "3", "D": "4"}, Force: true}
+
+	cases := []struct {
+		name     string
+		answers  string
+		terminal bool
+		fallback string
+		want     map[string]string
+		wantErr  error
+	}{
+		{name: "yes and no", answers: "y\nn\n\ny\n", terminal: true, want: map[string]string{"A": "1", "D": "4"}},
+		{name: "all remaining", answers: "n\na\n", terminal: true, want: map[string]string{"B": "new", "C": "3", "D": "4"}},
+		{name: "quit", answers: "y\nq\n", terminal: true, wantErr: field.ErrAborted},
+		{name: "answers run out", answers: "y", terminal: true, want: map[string]string{"A": "1"}},
+		{name: "no terminal applies none", want: map[string]string{}},
+		{name: "no terminal applies all", fallback: interactiveFallbackAll, want: plan.Vars},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out strings.Builder
+			s := &Service{
+				stdin:               strings.NewReader(tc.answers),
+				stdinIsTerminal:     tc.terminal,
+				interactiveFallback: tc.fallback,
+				stdout:              &out,
+				dst:                 &field.File{Data: map[string]string{"B": "old"}},
+			}
+
+			got, err := s.confirmPlan(plan)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("confirmPlan: %v", err)
+			}
+			if !mapsEqual(got.Vars, tc.want) || !got.Force {
+				t.Fatalf("got %+v, want %v", got, tc.want)
+			}
+			if tc.terminal && !strings.HasPrefix(out.String(), "apply A=1? [y/N/a/q] ") {
+				t.Fatalf("unexpected prompt %q", out.String())
+			}
+		})
+	}
+}
+
+func Test_Run_interactive(t *testing.T) {
+	t.Parallel()
+
+	secrets, err := parseKeyFilter(DefaultSecretPattern)
+	if err != nil {
+		t.Fatalf("parseKeyFilter: %v", err)
+	}
+
+	dstPath := writeTempFile(t, "API_TOKEN=old\n")
+	dst, err := readDstFile(filepath.Dir(dstPath), filepath.Base(dstPath), parser{})
+	if err != nil {
+		t.Fatalf("readDstFile: %v", err)
+	}
+
+	var out strings.Builder
+	s := &Service{
+		force:           true,
+		interactive:     true,
+		secrets:         secrets,
+		stdin:           strings.NewReader("n\ny\n"),
+		stdinIsTerminal: true,
+		stdout:          &out,
+		src:             map[string]string{"API_TOKEN": "t0ps3cret", "PORT": "80"},
+		dst:             dst,
+	}
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if want := "apply API_TOKEN: *** -> ***? [y/N/a/q] apply PORT=80? [y/N/a/q] "; out.String() != want {
+		t.Fatalf("prompts = %q, want %q", out.String(), want)
+	}
+	got := mustReadFile(t, dstPath)
+	if !strings.Contains(got, "PORT=80") || strings.Contains(got, "t0ps3cret") {
+		t.Fatalf("destination = %q", got)
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// interpolate expands ${VAR}, ${VAR:-default} and ${VAR:+alt} in the source
+// values of doc, as envsubst does: :- applies its default when VAR is unset
+// or empty, :+ its alternative when VAR is set and non-empty. VAR is looked up
+// in the keys defined above it, already expanded, then with lookupEnv.
+// Single-quoted values are literal. Other ${...} forms are left as written,
+// and an unset ${VAR} expands to nothing, unless strict makes them an error.
+func interpolate(doc *field.Document, lookupEnv func(string) (string, bool), strict bool) error {
+	defined := make(map[string]string)
+	lookup := func(name string) (string, bool) {
+		if v, ok := defined[name]; ok {
+			return v, true
+		}
+		return lookupEnv(name)
+	}
+
+	for i, e := range doc.Entries {
+		if e.Kind != field.KindVar {
+			continue
+		}
+
+		if !strings.HasPrefix(e.Value, "'") {
+			v, err := expandValue(e.Value, lookup, strict)
+			if err != nil {
+				return fmt.Errorf("%w for key %q on line %d: %w", field.ErrInterpolate, e.Key, e.Line, err)
+			}
+			doc.Entries[i].Value = v
+		}
+		defined[e.Key] = doc.Entries[i].Value
+	}
+
+	return nil
+}
+
+// expandValue expands every ${...} in value; see interpolate.
+func expandValue(value string, lookup func(string) (string, bool), strict bool) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			b.WriteString(value)
+			return b.String(), nil
+		}
+		b.WriteString(value[:start])
+
+		end := closingBrace(value[start+2:])
+		if end < 0 {
+			if strict {
+				return "", fmt.Errorf("unterminated %q", value[start:])
+			}
+			b.WriteString(value[start:])
+			return b.String(), nil
+		}
+		expr := value[start+2 : start+2+end]
+		value = value[start+2+end+1:]
+
+		v, ok, err := expandExpr(expr, lookup, strict)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			v = "${" + expr + "}"
+		}
+		b.WriteString(v)
+	}
+}
+
+// expandExpr expands the inside of one ${...}. It reports false for a form it
+// leaves literal.
+func expandExpr(expr string, lookup func(string) (string, bool), strict bool) (string, bool, error) {
+	name, op, word := expr, "", ""
+	if i := strings.IndexFunc(expr, func(r rune) bool { return !isNameRune(r) }); i >= 0 {
+		name, op, word = expr[:i], expr[i:min(i+2, len(expr))], expr[min(i+2, len(expr)):]
+	}
+
+	if !validName(name) || (op != "" && op != ":-" && op != ":+") {
+		if strict {
+			return "", false, fmt.Errorf("unsupported expression ${%s}", expr)
+		}
+		return "", false, nil
+	}
+
+	v, set := lookup(name)
+	switch op {
+	case ":-":
+		if v != "" {
+			return v, true, nil
+		}
+		w, err := expandValue(word, lookup, strict)
+		return w, err == nil, err
+	case ":+":
+		if v == "" {
+			return "", true, nil
+		}
+		w, err := expandValue(word, lookup, strict)
+		return w, err == nil, err
+	}
+
+	if !set && strict {
+		return "", false, fmt.Errorf("%s is not set", name)
+	}
+	return v, true, nil
+}
+
+// closingBrace returns the index in s of the } closing a ${ just before s,
+// skipping nested ${...}, or -1.
+func closingBrace(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case s[i] == '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+
+	return -1
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// validName reports whether name is a shell variable name.
+func validName(name string) bool {
+	return name != "" && (name[0] < '0' || name[0] > '9')
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_interpolate(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{"HOME": "/home/app", "EMPTY_ENV": "", "HOST": "os-host"}
+	lookupEnv := func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}
+
+	doc, err := parseDocument(strings.NewReader(
+		"HOST=db\nEMPTY=\n" +
+			"URL=\"postgres://${HOST}/app\"\n" +
+			"PORT=${DB_PORT:-5432}\n" +
+			"FROM_EMPTY=${EMPTY:-fallback}\n" +
+			"FROM_EMPTY_ENV=${EMPTY_ENV:-fallback}\n" +
+			"SET=${HOST:-unused}\n" +
+			"ALT=${HOST:+has-host}\n" +
+			"NO_ALT=${EMPTY:+never}${UNSET:+never}\n" +
+			"NESTED=${UNSET:-${HOME}/data}\n" +
+			"UNSET_PLAIN=a${UNSET}b\n" +
+			"ASSIGN=${UNSET:=x}\n" +
+			"LENGTH=${#HOST}\n" +
+			"OPEN=${HOST\n" +
+			"SINGLE='${HOST}'\n" +
+			"LATER=${AFTER:-before}\nAFTER=1\n"))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+
+	if err := interpolate(doc, lookupEnv, false); err != nil {
+		t.Fatalf("interpolate: %v", err)
+	}
+
+	want := map[string]string{
+		"HOST":           "db",
+		"EMPTY":          "",
+		"URL":            "postgres://db/app",
+		"PORT":           "5432",
+		"FROM_EMPTY":     "fallback",
+		"FROM_EMPTY_ENV": "fallback",
+		"SET":            "db",
+		"ALT":            "has-host",
+		"NO_ALT":         "",
+		"NESTED":         "/home/app/data",
+		"UNSET_PLAIN":    "ab",
+		"ASSIGN":         "${UNSET:=x}",
+		"LENGTH":         "${#HOST}",
+		"OPEN":           "${HOST",
+		"SINGLE":         "'${HOST}'",
+		"LATER":          "before",
+		"AFTER":          "1",
+	}
+	if got := doc.Map(); !mapsEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func Test_interpolate_strict(t *testing.T) {
+	t.Parallel()
+
+	lookupEnv := func(string) (string, bool) { return "", false }
+	for _, value := range []string{"${UNSET}", "${UNSET:=x}", "${#A}", "${A", "${1}"} {
+		doc, err := parseDocument(strings.NewReader("A=1\nB=" + value + "\n"))
+		if err != nil {
+			t.Fatalf("parseDocument: %v", err)
+		}
+
+		err = interpolate(doc, lookupEnv, true)
+		if !errors.Is(err, field.ErrInterpolate) {
+			t.Errorf("%s: err = %v, want ErrInterpolate", value, err)
+		}
+	}
+
+	doc, err := parseDocument(strings.NewReader("A=1\nB=${A:-x}${UNSET:-y}${UNSET:+z}\n"))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+	if err := interpolate(doc, lookupEnv, true); err != nil {
+		t.Fatalf("supported forms should expand in strict mode: %v", err)
+	}
+	if got := doc.Map()["B"]; got != "1y" {
+		t.Fatalf("B = %q, want 1y", got)
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// Destination formats accepted by --dst-format.
+const (
+	dstFormatEnv  = "env"
+	dstFormatJSON = "json"
+)
+
+// readJSONDstFile reads a JSON destination into a flat document, like a JSON
+// source. It also returns the keys whose values are JSON literals rather than
+// strings, so they are written back the same way. A missing file is empty.
+func readJSONDstFile(dir, file string) (*field.File, map[string]struct{}, error) {
+	filePath := resolvePath(dir, file)
+	slog.Default().Info("Reading file", "path", filePath)
+
+	env, literal := map[string]string{}, map[string]struct{}{}
+
+	content, err := os.Open(filePath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// Created on the first write.
+	case err != nil:
+		return nil, nil, fmt.Errorf("open %q: %w", filePath, err)
+	default:
+		env, literal, err = decodeJSON(content)
+		_ = content.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading file %q: %w", filePath, err)
+		}
+	}
+
+	doc := envDocument(env)
+	return &field.File{Path: filePath, Data: doc.Map(), Doc: doc}, literal, nil
+}
+
+// renderJSON returns the JSON destination with plan merged in. Keys are
+// nested again at jsonKeySeparator and values are JSON strings, except where
+// the destination held a literal that the value still is.
+func (s *Service) renderJSON(plan Plan) (string, error) {
+	env := make(map[string]string, len(s.dst.Data)+len(plan.Vars))
+	for k, v := range s.dst.Data {
+		env[k] = v
+	}
+	for k, v := range plan.Vars {
+		env[k] = v
+	}
+
+	keys := sortedKeys(env)
+	root := make(map[string]any)
+	for _, k := range keys {
+		var value any = env[k]
+		if _, ok := s.jsonLiterals[k]; ok {
+			switch v := env[k]; {
+			case v == "":
+				value = nil
+			case json.Valid([]byte(v)):
+				value = json.RawMessage(v)
+			}
+		}
+
+		if err := setJSONPath(root, strings.Split(k, jsonKeySeparator), value); err != nil {
+			return "", fmt.Errorf("key %q: %w", k, err)
+		}
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return "", fmt.Errorf("error encoding JSON destination: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+// setJSONPath stores value under the nested path in root. A key that is both
+// a value and an object, like DB and DB__HOST, cannot be represented.
+func setJSONPath(root map[string]any, path []string, value any) error {
+	node := root
+	for i, part := range path[:len(path)-1] {
+		child, ok := node[part]
+		if !ok {
+			next := make(map[string]any)
+			node[part] = next
+			node = next
+			continue
+		}
+
+		next, ok := child.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%q already holds a value and cannot nest keys", strings.Join(path[:i+1], jsonKeySeparator))
+		}
+		node = next
+	}
+
+	last := path[len(path)-1]
+	if child, ok := node[last].(map[string]any); ok {
+		nested := make([]string, 0, len(child))
+		for k := range child {
+			nested = append(nested, k)
+		}
+		sort.Strings(nested)
+		return fmt.Errorf("value conflicts with nested keys %s", strings.Join(nested, ", "))
+	}
+	node[last] = value
+
+	return nil
+}
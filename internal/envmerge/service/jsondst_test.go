@@ -0,0 +1,92 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_Run_jsonDestination(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	dstPath := filepath.Join(tmpDir, "config.json")
+	existing := `{
+  // local settings
+  "DB": {"HOST": "localhost", "PORT": 5432},
+  "DEBUG": true,
+  "TAGS": ["a", "b"],
+  "EMPTY": null,
+}`
+	if err := os.WriteFile(dstPath, []byte(existing), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	dst, literals, err := readJSONDstFile(tmpDir, "config.json")
+	if err != nil {
+		t.Fatalf("readJSONDstFile: %v", err)
+	}
+
+	s := &Service{
+		dstFormat:    dstFormatJSON,
+		jsonLiterals: literals,
+		src: map[string]string{
+			"DB__HOST":   "db",
+			"DB__USER":   "app <admin>",
+			"DEBUG":      "false",
+			"CACHE__TTL": "60",
+		},
+		dst: dst,
+	}
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	want := `{
+  "CACHE": {
+    "TTL": "60"
+  },
+  "DB": {
+    "HOST": "localhost",
+    "PORT": 5432,
+    "USER": "app <admin>"
+  },
+  "DEBUG": true,
+  "EMPTY": null,
+  "TAGS": [
+    "a",
+    "b"
+  ]
+}
+`
+	if got := mustReadFile(t, dstPath); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func Test_renderJSON_literalsAndConflicts(t *testing.T) {
+	t.Parallel()
+
+	s := &Service{
+		dstFormat:    dstFormatJSON,
+		jsonLiterals: map[string]struct{}{"PORT": {}},
+		src:          map[string]string{"PORT": "not a number"},
+		dst:          &field.File{Data: map[string]string{"PORT": "8080"}},
+	}
+
+	got, err := s.renderJSON(Plan{Vars: map[string]string{"PORT": "not a number"}})
+	if err != nil {
+		t.Fatalf("renderJSON: %v", err)
+	}
+	if want := "{\n  \"PORT\": \"not a number\"\n}\n"; got != want {
+		t.Fatalf("an invalid literal falls back to a string, got %q", got)
+	}
+
+	_, err = s.renderJSON(Plan{Vars: map[string]string{"PORT__INNER": "1"}})
+	if err == nil || !strings.Contains(err.Error(), "PORT") {
+		t.Fatalf("expected a nesting conflict error, got %v", err)
+	}
+}
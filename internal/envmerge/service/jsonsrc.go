@@ -0,0 +1,238 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// jsonKeySeparator joins the keys of nested JSON objects: {"DB": {"HOST": x}}
+// becomes DB__HOST.
+const jsonKeySeparator = "__"
+
+// sourceDocument parses a source by its name: .json files as JSON, anything
+// else as a dotenv file. Encrypted sources are decrypted first.
+func (p parser) sourceDocument(name string, r io.Reader) (*field.Document, error) {
+	p.file = name
+	r, name, err := decryptSource(name, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(path.Ext(name), ".json") {
+		return jsonDocument(r)
+	}
+
+	return p.document(r)
+}
+
+// jsonDocument reads a JSON object source. Comments and trailing commas are
+// tolerated so hand-edited files parse; nested objects are flattened.
+func jsonDocument(r io.Reader) (*field.Document, error) {
+	env, _, err := decodeJSON(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return envDocument(env), nil
+}
+
+// decodeJSON reads and flattens a JSON object. literal holds the keys whose
+// values were numbers, booleans, null or arrays rather than strings.
+func decodeJSON(r io.Reader) (env map[string]string, literal map[string]struct{}, err error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading input: %w", err)
+	}
+
+	clean := relaxJSON(data)
+
+	dec := json.NewDecoder(bytes.NewReader(clean))
+	dec.UseNumber()
+
+	var root map[string]any
+	if err := dec.Decode(&root); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			// Offset counts the bytes read, including the offending one.
+			line, col := position(clean, max(syntaxErr.Offset-1, 0))
+			return nil, nil, fmt.Errorf("invalid JSON at line %d, column %d: %w", line, col, err)
+		}
+		return nil, nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	env, literal = make(map[string]string), make(map[string]struct{})
+	if err := flattenJSON("", root, env, literal); err != nil {
+		return nil, nil, err
+	}
+
+	return env, literal, nil
+}
+
+// envDocument builds a document of sorted KEY=value lines from env.
+func envDocument(env map[string]string) *field.Document {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	doc := &field.Document{Entries: make([]field.Entry, 0, len(keys))}
+	for i, k := range keys {
+		doc.Entries = append(doc.Entries, field.Entry{
+			Kind:  field.KindVar,
+			Key:   k,
+			Value: env[k],
+			Raw:   fmt.Sprintf("%s=%s\n", k, formatEnvValue(env[k])),
+			Line:  i + 1,
+		})
+	}
+
+	return doc
+}
+
+func flattenJSON(prefix string, v any, env map[string]string, literal map[string]struct{}) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if k == "" {
+				return fmt.Errorf("%w in JSON object %q", field.ErrEmptyKey, prefix)
+			}
+			if prefix != "" {
+				k = prefix + jsonKeySeparator + k
+			}
+			if err := flattenJSON(k, child, env, literal); err != nil {
+				return err
+			}
+		}
+		return nil
+	case string:
+		env[prefix] = v
+		return nil
+	}
+
+	literal[prefix] = struct{}{}
+	switch v := v.(type) {
+	case json.Number:
+		env[prefix] = v.String()
+	case bool:
+		env[prefix] = fmt.Sprint(v)
+	case nil:
+		env[prefix] = ""
+	default:
+		// Arrays keep their JSON form.
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", prefix, err)
+		}
+		env[prefix] = string(b)
+	}
+
+	return nil
+}
+
+// relaxJSON blanks out // and /* */ comments and trailing commas outside
+// strings. Every removed byte becomes a space (newlines are kept), so offsets
+// in the result still point at the same line and column as in data.
+func relaxJSON(data []byte) []byte {
+	out := bytes.Clone(data)
+
+	inString := false
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			stop := len(out)
+			if end >= 0 {
+				stop = i + 2 + end + 2
+			}
+			for ; i < stop; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			i--
+		case c == ',':
+			j := i + 1
+			for j < len(out) && isJSONSpaceOrComment(out, j) {
+				j = skipJSONSpaceOrComment(out, j)
+			}
+			if j < len(out) && (out[j] == '}' || out[j] == ']') {
+				out[i] = ' '
+			}
+		}
+	}
+
+	return out
+}
+
+func isJSONSpaceOrComment(b []byte, i int) bool {
+	switch b[i] {
+	case ' ', '\t', '\r', '\n':
+		return true
+	case '/':
+		return i+1 < len(b) && (b[i+1] == '/' || b[i+1] == '*')
+	}
+
+	return false
+}
+
+// skipJSONSpaceOrComment returns the index just past the whitespace or comment
+// at i.
+func skipJSONSpaceOrComment(b []byte, i int) int {
+	if b[i] != '/' {
+		return i + 1
+	}
+
+	if b[i+1] == '/' {
+		end := bytes.IndexByte(b[i:], '\n')
+		if end < 0 {
+			return len(b)
+		}
+		return i + end
+	}
+
+	end := bytes.Index(b[i+2:], []byte("*/"))
+	if end < 0 {
+		return len(b)
+	}
+	return i + 2 + end + 2
+}
+
+// position converts the byte index offset into a 1-based line and column.
+func position(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n')
+
+	return line, col
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+func Test_jsonDocument(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		in      string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "strict",
+			in:   `{"PORT": 8080, "DEBUG": true, "NAME": "my app", "EMPTY": null, "DB": {"HOST": "localhost", "PORT": 5432}, "HOSTS": ["a", "b"]}`,
+			want: map[string]string{
+				"PORT": "8080", "DEBUG": "true", "NAME": "my app", "EMPTY": "",
+				"DB__HOST": "localhost", "DB__PORT": "5432", "HOSTS": `["a","b"]`,
+			},
+		},
+		{
+			name: "comments and trailing commas",
+			in: `{
+  // the port
+  "PORT": 8080, /* inline */
+  "URL": "http://x//not-a-comment",
+  "LIST": [1, 2,],
+  "NESTED": {"A": "a,}",}, // trailing
+}`,
+			want: map[string]string{"PORT": "8080", "URL": "http://x//not-a-comment", "LIST": "[1,2]", "NESTED__A": "a,}"},
+		},
+		{
+			name:    "syntax error position",
+			in:      "{\n  \"A\": 1,\n  \"B\" 2\n}",
+			wantErr: "line 3, column 7",
+		},
+		{
+			name:    "not an object",
+			in:      `["A"]`,
+			wantErr: "invalid JSON",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			doc, err := jsonDocument(strings.NewReader(tc.in))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("jsonDocument: %v", err)
+			}
+
+			if got := doc.Map(); !mapsEqual(got, tc.want) {
+				t.Fatalf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_readSources_jsonSource(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"env.json": &fstest.MapFile{Data: []byte(`{"A": "1", "B": {"C": 2},}`)},
+	}
+
+	got, err := readSources("", fsys, config.Config{Src: "env.json"}, parser{})
+	if err != nil {
+		t.Fatalf("readSources: %v", err)
+	}
+	if !mapsEqual(got.Map(), map[string]string{"A": "1", "B__C": "2"}) {
+		t.Fatalf("got %#v", got.Map())
+	}
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// parseWarning is a malformed line that lenient parsing skipped.
+type parseWarning struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (w parseWarning) String() string {
+	return fmt.Sprintf("%s:%d: %v", w.File, w.Line, w.Err)
+}
+
+// tolerate handles a malformed line. Strict parsing fails with err; lenient
+// parsing records a warning and keeps the line verbatim as a comment, so the
+// file still round-trips.
+func (p parser) tolerate(doc *field.Document, rawLine string, lineNo int, err error) error {
+	if !p.lenient {
+		return err
+	}
+
+	if p.warnings != nil {
+		*p.warnings = append(*p.warnings, parseWarning{File: p.file, Line: lineNo, Err: err})
+	}
+	doc.Entries = append(doc.Entries, field.Entry{Kind: field.KindComment, Raw: rawLine, Line: lineNo})
+
+	return nil
+}
+
+// isTolerated reports whether e is a malformed line lenient parsing kept as a
+// comment; a real comment always starts with "#".
+func isTolerated(e field.Entry) bool {
+	return e.Kind == field.KindComment && !strings.HasPrefix(strings.TrimSpace(e.Raw), "#")
+}
+
+// logParseWarnings reports every line lenient parsing skipped.
+func logParseWarnings(warnings []parseWarning) {
+	for _, w := range warnings {
+		slog.Default().Warn("skipped malformed line", "path", w.File, "line", w.Line, "error", w.Err)
+	}
+}
+
+// checkParseWarnings fails a --fail-on-parse-warning run that skipped lines.
+func (s *Service) checkParseWarnings() error {
+	if !s.failOnParseWarning || len(s.parseWarnings) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d skipped, first at %s", field.ErrParseWarnings, len(s.parseWarnings), s.parseWarnings[0])
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_parser_lenient(t *testing.T) {
+	t.Parallel()
+
+	in := "A=1\nnot a pair\n=orphan\nB=\"x\" y\nC=3\n"
+
+	if _, err := parseDocument(strings.NewReader(in)); err == nil {
+		t.Fatal("strict parsing: expected an error")
+	}
+
+	var warnings []parseWarning
+	p := parser{lenient: true, warnings: &warnings, file: ".env"}
+	doc, err := p.document(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("lenient document: %v", err)
+	}
+
+	if got := doc.Map(); !mapsEqual(got, map[string]string{"A": "1", "C": "3"}) {
+		t.Fatalf("Map = %v", got)
+	}
+	if doc.String() != in {
+		t.Fatalf("round trip: got %q, want %q", doc.String(), in)
+	}
+
+	var lines []string
+	for _, w := range warnings {
+		lines = append(lines, w.String())
+	}
+	want := []string{
+		`.env:2: invalid env line: "not a pair"`,
+		`.env:3: empty key on line 3: "=orphan"`,
+		`.env:4: invalid value for key "B": unexpected text after closing quote: " y"`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("warnings\ngot:  %q\nwant: %q", lines, want)
+	}
+	if !errors.Is(warnings[1].Err, field.ErrEmptyKey) {
+		t.Fatalf("expected ErrEmptyKey, got %v", warnings[1].Err)
+	}
+}
+
+func Test_Run_failOnParseWarning(t *testing.T) {
+	t.Parallel()
+
+	for _, dryRun := range []bool{false, true} {
+		tmpDir := t.TempDir()
+		dstPath := filepath.Join(tmpDir, ".env")
+		if err := os.WriteFile(dstPath, []byte("A=1\nbroken\n"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+
+		var warnings []parseWarning
+		dst, err := readDstFile(tmpDir, ".env", parser{lenient: true, warnings: &warnings})
+		if err != nil {
+			t.Fatalf("readDstFile: %v", err)
+		}
+
+		var out strings.Builder
+		s := &Service{
+			dryRun:             dryRun,
+			failOnParseWarning: true,
+			parseWarnings:      warnings,
+			src:                map[string]string{"A": "1", "B": "2"},
+			dst:                dst,
+			stdout:             &out,
+		}
+
+		if err := s.Run(); !errors.Is(err, field.ErrParseWarnings) {
+			t.Fatalf("dryRun=%v: expected ErrParseWarnings, got %v", dryRun, err)
+		}
+		if got := mustReadFile(t, dstPath); got != "A=1\nbroken\n" {
+			t.Fatalf("dryRun=%v: destination was written: %q", dryRun, got)
+		}
+		if dryRun && !strings.Contains(out.String(), "B") {
+			t.Fatalf("dry run should still print the plan, got %q", out.String())
+		}
+	}
+}
+
+func Test_Run_failOnParseWarningBeforeOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		set  func(s *Service, dir string)
+	}{
+		{name: "print effective", set: func(s *Service, _ string) { s.effective = true }},
+		{name: "checksum", set: func(s *Service, _ string) { s.checksum = true }},
+		{name: "blank values", set: func(s *Service, _ string) { s.blankValues = true }},
+		{name: "format sh", set: func(s *Service, _ string) { s.format = formatSh }},
+		{name: "patch", set: func(s *Service, dir string) { s.patchPath = filepath.Join(dir, "env.patch") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, ".env"), []byte("A=1\nbroken\n"), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			var warnings []parseWarning
+			dst, err := readDstFile(tmpDir, ".env", parser{lenient: true, warnings: &warnings})
+			if err != nil {
+				t.Fatalf("readDstFile: %v", err)
+			}
+
+			var out strings.Builder
+			s := &Service{
+				failOnParseWarning: true,
+				parseWarnings:      warnings,
+				src:                map[string]string{"A": "1", "B": "2"},
+				dst:                dst,
+				stdout:             &out,
+			}
+			tt.set(s, tmpDir)
+
+			if err := s.Run(); !errors.Is(err, field.ErrParseWarnings) {
+				t.Fatalf("expected ErrParseWarnings, got %v", err)
+			}
+			if out.Len() > 0 {
+				t.Fatalf("printed %q", out.String())
+			}
+			if _, err := os.Stat(filepath.Join(tmpDir, "env.patch")); err == nil {
+				t.Fatal("patch was written")
+			}
+		})
+	}
+}
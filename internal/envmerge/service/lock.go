@@ -0,0 +1,158 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// lockSchemaVersion 2 added the salt; version 1 locks are still read.
+const lockSchemaVersion = 2
+
+// lockFile records a hash of each key's value as of the last sync, so a later
+// run can tell local edits from values it wrote itself.
+type lockFile struct {
+	SchemaVersion int `json:"schema_version"`
+	// Salt is the hex HMAC key of the hashes, random per lock file. It rules
+	// out precomputed tables and comparing hashes across lock files, but it
+	// is stored next to the hashes, so anyone holding the lock can still
+	// brute-force a short value.
+	Salt string            `json:"salt,omitempty"`
+	Keys map[string]string `json:"keys"`
+}
+
+// hash hashes value together with its key, so equal values under different
+// keys don't share a hash. A version 1 lock has no salt and plain SHA-256
+// hashes.
+func (l lockFile) hash(key, value string) string {
+	msg := []byte(key + "\x00" + value)
+	salt, err := hex.DecodeString(l.Salt)
+	if l.Salt == "" || err != nil {
+		sum := sha256.Sum256(msg)
+		return hex.EncodeToString(sum[:])
+	}
+
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(msg)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newLock returns an empty lock with the salt of prev, or a new one when prev
+// has none.
+func newLock(prev lockFile) (lockFile, error) {
+	lock := lockFile{SchemaVersion: lockSchemaVersion, Salt: prev.Salt, Keys: map[string]string{}}
+	if lock.Salt != "" {
+		return lock, nil
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return lock, fmt.Errorf("error generating lock salt: %w", err)
+	}
+	lock.Salt = hex.EncodeToString(salt)
+
+	return lock, nil
+}
+
+func (s *Service) lockPath() string {
+	return s.dst.Path + ".lock"
+}
+
+func (s *Service) readLock() (lockFile, error) {
+	lock := lockFile{Keys: map[string]string{}}
+
+	data, err := os.ReadFile(s.lockPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return lock, nil
+	}
+	if err != nil {
+		return lock, fmt.Errorf("error reading lock file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return lock, fmt.Errorf("error decoding lock file %q: %w", s.lockPath(), err)
+	}
+	if lock.Keys == nil {
+		lock.Keys = map[string]string{}
+	}
+
+	return lock, nil
+}
+
+// lockConflicts returns the sorted keys plan overwrites although their
+// destination value was edited since the last sync.
+func (s *Service) lockConflicts(plan Plan, lock lockFile) []string {
+	var keys []string
+	for _, k := range sortedKeys(plan.Vars) {
+		current, ok := s.dst.Data[k]
+		if !ok {
+			continue
+		}
+
+		locked, ok := lock.Keys[k]
+		if ok && locked != lock.hash(k, current) && current != s.src[k] {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+// writeLock records the post-merge value of every source key, or with
+// --three-way the ancestor the next merge compares against.
+func (s *Service) writeLock(plan Plan) error {
+	prev := s.ancestor
+	if !s.threeWay {
+		var err error
+		if prev, err = s.readLock(); err != nil {
+			return err
+		}
+	}
+	lock, err := newLock(prev)
+	if err != nil {
+		return err
+	}
+
+	for k := range s.src {
+		if s.threeWay {
+			s.lockMerged(lock, k)
+			continue
+		}
+
+		v, ok := plan.Vars[k]
+		if !ok {
+			v, ok = s.dst.Data[k]
+		}
+		if ok {
+			lock.Keys[k] = lock.hash(k, v)
+		}
+	}
+
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding lock file: %w", err)
+	}
+
+	return os.WriteFile(s.lockPath(), append(data, '\n'), 0o644)
+}
+
+// checkLock warns about keys about to lose local edits made since the last
+// sync.
+func (s *Service) checkLock(plan Plan) error {
+	lock, err := s.readLock()
+	if err != nil {
+		return err
+	}
+
+	for _, k := range s.lockConflicts(plan, lock) {
+		slog.Default().Warn("destination value changed since last sync and will be overwritten", "key", k)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_lock_detectsLocalEdits(t *testing.T) {
+	t.Parallel()
+
+	dst := &field.File{
+		Path: filepath.Join(t.TempDir(), ".env"),
+		Data: map[string]string{"A": "1", "B": "1"},
+	}
+	s := &Service{force: true, src: map[string]string{"A": "1", "B": "1", "SECRET": "hunter2"}, dst: dst}
+
+	plan := Plan{Vars: map[string]string{"SECRET": "hunter2"}, Force: true}
+	if err := s.writeLock(plan); err != nil {
+		t.Fatalf("writeLock: %v", err)
+	}
+	if content := mustReadFile(t, s.lockPath()); strings.Contains(content, "hunter2") {
+		t.Fatalf("lock file leaks a value:\n%s", content)
+	}
+
+	lock, err := s.readLock()
+	if err != nil {
+		t.Fatalf("readLock: %v", err)
+	}
+	if len(lock.Keys) != 3 || lock.Salt == "" || lock.Keys["SECRET"] != lock.hash("SECRET", "hunter2") {
+		t.Fatalf("unexpected lock: %#v", lock)
+	}
+
+	// A is edited by hand, then the source changes both keys.
+	dst.Data["A"] = "local"
+	s.src = map[string]string{"A": "2", "B": "2", "SECRET": "hunter2"}
+
+	got := s.lockConflicts(Plan{Vars: map[string]string{"A": "2", "B": "2"}, Force: true}, lock)
+	if strings.Join(got, ",") != "A" {
+		t.Fatalf("conflicts = %v, want [A]", got)
+	}
+}
+
+func Test_readLock_missingIsEmpty(t *testing.T) {
+	t.Parallel()
+
+	s := &Service{dst: &field.File{Path: filepath.Join(t.TempDir(), ".env")}}
+	lock, err := s.readLock()
+	if err != nil {
+		t.Fatalf("readLock: %v", err)
+	}
+	if len(lock.Keys) != 0 {
+		t.Fatalf("expected empty lock, got %#v", lock)
+	}
+}
+
+func Test_lock_salt(t *testing.T) {
+	t.Parallel()
+
+	dst := &field.File{Path: filepath.Join(t.TempDir(), ".env"), Data: map[string]string{"PIN": "1234"}}
+	s := &Service{src: map[string]string{"PIN": "1234"}, dst: dst}
+
+	if err := s.writeLock(Plan{}); err != nil {
+		t.Fatalf("writeLock: %v", err)
+	}
+	first, err := s.readLock()
+	if err != nil {
+		t.Fatalf("readLock: %v", err)
+	}
+	unsalted := sha256.Sum256([]byte("PIN\x001234"))
+	if first.Salt == "" || first.Keys["PIN"] == hex.EncodeToString(unsalted[:]) {
+		t.Fatalf("lock is not salted: %#v", first)
+	}
+
+	// Rewriting keeps the salt, so an unchanged lock stays byte for byte.
+	before := mustReadFile(t, s.lockPath())
+	if err := s.writeLock(Plan{}); err != nil {
+		t.Fatalf("writeLock: %v", err)
+	}
+	if after := mustReadFile(t, s.lockPath()); after != before {
+		t.Fatalf("lock changed:\n%s\nwant\n%s", after, before)
+	}
+}
+
+func Test_lock_readsVersion1(t *testing.T) {
+	t.Parallel()
+
+	dst := &field.File{Path: filepath.Join(t.TempDir(), ".env"), Data: map[string]string{"A": "1", "B": "local"}}
+	sum := func(key, value string) string {
+		h := sha256.Sum256([]byte(key + "\x00" + value))
+		return hex.EncodeToString(h[:])
+	}
+	v1 := `{"schema_version": 1, "keys": {"A": "` + sum("A", "1") + `", "B": "` + sum("B", "1") + `"}}`
+	if err := os.WriteFile(dst.Path+".lock", []byte(v1), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	s := &Service{src: map[string]string{"A": "2", "B": "2"}, dst: dst}
+	lock, err := s.readLock()
+	if err != nil {
+		t.Fatalf("readLock: %v", err)
+	}
+	got := s.lockConflicts(Plan{Vars: map[string]string{"A": "2", "B": "2"}, Force: true}, lock)
+	if strings.Join(got, ",") != "B" {
+		t.Fatalf("conflicts = %v, want [B]", got)
+	}
+
+	if err := s.writeLock(Plan{Vars: map[string]string{"A": "2", "B": "2"}}); err != nil {
+		t.Fatalf("writeLock: %v", err)
+	}
+	if lock, err = s.readLock(); err != nil || lock.SchemaVersion != lockSchemaVersion || lock.Salt == "" {
+		t.Fatalf("lock not upgraded: %#v, %v", lock, err)
+	}
+}
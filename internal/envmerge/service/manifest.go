@@ -0,0 +1,92 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// manifestSchemaVersion is bumped whenever the manifest changes incompatibly.
+const manifestSchemaVersion = 1
+
+// manifest describes the env surface envmerge manages: every source key with
+// what other tools need to render or validate it. It never holds values.
+type manifest struct {
+	SchemaVersion int           `json:"schema_version"`
+	Source        string        `json:"source"`
+	Keys          []manifestKey `json:"keys"`
+}
+
+type manifestKey struct {
+	Key string `json:"key"`
+	// Required is set when the source gives no usable default: its value
+	// is empty or a placeholder.
+	Required    bool   `json:"required"`
+	Secret      bool   `json:"secret"`
+	Multiline   bool   `json:"multiline"`
+	Type        string `json:"type,omitempty"`
+	Group       string `json:"group,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// buildManifest describes the source keys in sorted order, skipping excluded
+// ones.
+func (s *Service) buildManifest() manifest {
+	m := manifest{SchemaVersion: manifestSchemaVersion, Source: s.srcName, Keys: []manifestKey{}}
+	for _, k := range sortedKeys(s.src) {
+		if !s.selected(k) {
+			continue
+		}
+
+		v := s.src[k]
+		e := s.srcEntries[k]
+
+		var doc []string
+		for _, c := range e.Doc {
+			// envmerge's own annotations are not descriptions.
+			if _, meta := metaComment(c); meta && e.Meta != nil {
+				continue
+			}
+			if c = strings.TrimSpace(strings.TrimPrefix(c, "#")); c != "" && !strings.HasPrefix(c, "envmerge:") {
+				doc = append(doc, c)
+			}
+		}
+
+		key := manifestKey{
+			Key:         k,
+			Required:    v == "" || s.isPlaceholder(v),
+			Secret:      s.isSecret(k),
+			Multiline:   strings.ContainsAny(v, "\r\n"),
+			Type:        e.Type,
+			Group:       s.metaString(k, "group"),
+			Description: strings.Join(doc, " "),
+		}
+		// Metadata states what the value can only suggest.
+		if required, ok := e.Meta["required"].(bool); ok {
+			key.Required = required
+		}
+		if d := s.metaString(k, "description"); d != "" {
+			key.Description = d
+		}
+		m.Keys = append(m.Keys, key)
+	}
+
+	return m
+}
+
+// writeManifest regenerates the manifest file.
+func (s *Service) writeManifest() error {
+	data, err := json.MarshalIndent(s.buildManifest(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	slog.Default().Info("Writing file", "path", s.manifestPath)
+	if err := os.WriteFile(s.manifestPath, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write %q: %w", s.manifestPath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func Test_Run_writesManifest(t *testing.T) {
+	t.Parallel()
+
+	srcDoc, err := parser{typeHints: true}.document(strings.NewReader(
+		"# Port the HTTP server listens on\nPORT=8080 # type:int\n" +
+			"API_TOKEN=changeme\n" +
+			"# envmerge:managed\nTLS_CERT=\"-----BEGIN-----\nabc\n-----END-----\"\n" +
+			"EMPTY=\n"))
+	if err != nil {
+		t.Fatalf("document: %v", err)
+	}
+	secrets, err := parseKeyFilter(DefaultSecretPattern)
+	if err != nil {
+		t.Fatalf("parseKeyFilter: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	dst, err := readDstFile(tmpDir, ".env", parser{})
+	if err != nil {
+		t.Fatalf("readDstFile: %v", err)
+	}
+
+	manifestPath := filepath.Join(tmpDir, ".env.manifest")
+	s := &Service{
+		srcName:      ".env.example",
+		src:          srcDoc.Map(),
+		srcEntries:   srcDoc.Vars(),
+		dst:          dst,
+		secrets:      secrets,
+		placeholder:  regexp.MustCompile(DefaultPlaceholderPattern),
+		manifestPath: manifestPath,
+	}
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	var got manifest
+	if err := json.Unmarshal([]byte(mustReadFile(t, manifestPath)), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	want := manifest{
+		SchemaVersion: manifestSchemaVersion,
+		Source:        ".env.example",
+		Keys: []manifestKey{
+			{Key: "API_TOKEN", Required: true, Secret: true},
+			{Key: "EMPTY", Required: true},
+			{Key: "PORT", Type: "int", Description: "Port the HTTP server listens on"},
+			{Key: "TLS_CERT", Multiline: true},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got  %+v\nwant %+v", got, want)
+	}
+	if strings.Contains(mustReadFile(t, manifestPath), "8080") {
+		t.Fatal("manifest must not contain values")
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// mapArrow separates the match from the replacement in a --map-file line.
+const mapArrow = " -> "
+
+// mapAnyKey as the key of a --map-file line matches every key.
+const mapAnyKey = "*"
+
+// valueMap is a --map-file: source value replacements looked up by key and
+// value ("KEY=old -> new"), by key alone ("KEY -> new") or by value for every
+// key ("*=old -> new"), in that order of precedence.
+type valueMap struct {
+	byKeyValue map[[2]string]string
+	byKey      map[string]string
+	byValue    map[string]string
+}
+
+// parseValueMap reads "KEY=old -> new", "KEY -> new" and "*=old -> new"
+// lines. Values may be double-quoted to keep surrounding spaces.
+func parseValueMap(lines []string) (*valueMap, error) {
+	m := &valueMap{
+		byKeyValue: map[[2]string]string{},
+		byKey:      map[string]string{},
+		byValue:    map[string]string{},
+	}
+
+	for _, line := range lines {
+		match, repl, ok := strings.Cut(line, mapArrow)
+		if !ok {
+			return nil, fmt.Errorf("invalid value map %q: want KEY=OLD -> NEW, KEY -> NEW or *=OLD -> NEW", line)
+		}
+		repl = unquoteMapValue(repl)
+
+		key, old, hasValue := strings.Cut(match, "=")
+		key = strings.TrimSpace(key)
+		if key == "" || (key == mapAnyKey && !hasValue) {
+			return nil, fmt.Errorf("invalid value map %q: want KEY=OLD -> NEW, KEY -> NEW or *=OLD -> NEW", line)
+		}
+
+		switch {
+		case !hasValue:
+			m.byKey[key] = repl
+		case key == mapAnyKey:
+			m.byValue[unquoteMapValue(old)] = repl
+		default:
+			m.byKeyValue[[2]string{key, unquoteMapValue(old)}] = repl
+		}
+	}
+
+	return m, nil
+}
+
+// unquoteMapValue trims v and drops one pair of surrounding double quotes.
+func unquoteMapValue(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && strings.HasPrefix(v, `"`) && strings.HasSuffix(v, `"`) {
+		return v[1 : len(v)-1]
+	}
+
+	return v
+}
+
+func readValueMapFile(dir, file string) (*valueMap, error) {
+	filePath := resolvePath(dir, file)
+	slog.Default().Info("Reading file", "path", filePath)
+
+	content, err := os.Open(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, field.ErrFileDoesNotExist
+		}
+		return nil, fmt.Errorf("open %q: %w", filePath, err)
+	}
+	defer content.Close()
+
+	// The file has the same blank and comment rules as a rename file.
+	lines, err := renameList(content)
+	if err == nil {
+		var m *valueMap
+		if m, err = parseValueMap(lines); err == nil {
+			return m, nil
+		}
+	}
+
+	return nil, fmt.Errorf("error reading file %q: %w", filePath, err)
+}
+
+// upperKeys upper-cases the keys m matches on, to line up with --upper-keys.
+func (m *valueMap) upperKeys() {
+	byKeyValue := make(map[[2]string]string, len(m.byKeyValue))
+	for kv, r := range m.byKeyValue {
+		byKeyValue[[2]string{strings.ToUpper(kv[0]), kv[1]}] = r
+	}
+	byKey := make(map[string]string, len(m.byKey))
+	for k, r := range m.byKey {
+		byKey[strings.ToUpper(k)] = r
+	}
+	m.byKeyValue, m.byKey = byKeyValue, byKey
+}
+
+// apply returns the replacement for key's value v, or v when nothing matches.
+// Replacements are not mapped again.
+func (m *valueMap) apply(key, v string) string {
+	if r, ok := m.byKeyValue[[2]string{key, v}]; ok {
+		return r
+	}
+	if r, ok := m.byKey[key]; ok {
+		return r
+	}
+	if r, ok := m.byValue[v]; ok {
+		return r
+	}
+
+	return v
+}
+
+// mapValues rewrites the source values through the --map-file.
+func (s *Service) mapValues() {
+	if s.valueMap == nil {
+		return
+	}
+
+	for k, v := range s.src {
+		s.src[k] = s.valueMap.apply(k, v)
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+func Test_valueMap_apply(t *testing.T) {
+	t.Parallel()
+
+	m, err := parseValueMap([]string{
+		"DB_HOST=dev-db -> prod-db",
+		"LOG_LEVEL -> warn",
+		"*=dev-db -> shared-db",
+		`*=" padded " -> "  kept  "`,
+		"URL=http://a?x=1 -> http://b?x=2",
+	})
+	if err != nil {
+		t.Fatalf("parseValueMap: %v", err)
+	}
+
+	tests := []struct{ key, value, want string }{
+		{"DB_HOST", "dev-db", "prod-db"},
+		{"REPLICA_HOST", "dev-db", "shared-db"},
+		{"LOG_LEVEL", "debug", "warn"},
+		{"OTHER", " padded ", "  kept  "},
+		{"URL", "http://a?x=1", "http://b?x=2"},
+		{"DB_HOST", "dev-db.internal", "dev-db.internal"},
+		{"UNMAPPED", "prod-db", "prod-db"},
+	}
+	for _, tt := range tests {
+		if got := m.apply(tt.key, tt.value); got != tt.want {
+			t.Errorf("apply(%s, %q) = %q, want %q", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func Test_parseValueMap_invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, line := range []string{"DB_HOST=dev-db", "-> x", "* -> x", "=old -> new"} {
+		if _, err := parseValueMap([]string{line}); err == nil {
+			t.Errorf("parseValueMap(%q) should fail", line)
+		}
+	}
+}
+
+func Test_Run_mapFile(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, ".env.example")
+	dstPath := filepath.Join(tmpDir, ".env")
+	mapPath := filepath.Join(tmpDir, "map.env")
+	for path, content := range map[string]string{
+		srcPath: "DB_HOST=dev-db\nCACHE_HOST=dev-db\nLOG_LEVEL=debug\nPORT=5432\n",
+		dstPath: "PORT=5432\nCACHE_HOST=prod-db\n",
+		mapPath: "# dev to prod\n*=dev-db -> prod-db\n\nLOG_LEVEL -> warn\n",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	s, err := New(config.Config{Src: srcPath, Dst: dstPath, MapFile: mapPath, Force: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	// CACHE_HOST maps to the value the destination already has.
+	if want := map[string]string{"DB_HOST": "prod-db", "LOG_LEVEL": "warn"}; !mapsEqual(s.Plan().Vars, want) {
+		t.Fatalf("plan = %v, want %v", s.Plan().Vars, want)
+	}
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if got := mustReadFile(t, dstPath); strings.Contains(got, "dev-db") {
+		t.Fatalf("unmapped value written: %q", got)
+	}
+}
+
+func Test_Run_mapFileUpperKeys(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, ".env.example")
+	dstPath := filepath.Join(tmpDir, ".env")
+	mapPath := filepath.Join(tmpDir, "map.env")
+	for path, content := range map[string]string{
+		srcPath: "db_host=dev-db\nlog_level=debug\n",
+		mapPath: "db_host=dev-db -> prod-db\nlog_level -> warn\n",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	s, err := New(config.Config{Src: srcPath, Dst: dstPath, MapFile: mapPath, UpperKeys: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if want := map[string]string{"DB_HOST": "prod-db", "LOG_LEVEL": "warn"}; !mapsEqual(s.Plan().Vars, want) {
+		t.Fatalf("plan = %v, want %v", s.Plan().Vars, want)
+	}
+}
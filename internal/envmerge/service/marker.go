@@ -0,0 +1,79 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// insertMarker is a destination comment that pins where new sync blocks go.
+const insertMarker = "# envmerge:insert-here"
+
+func insertMarkerIndex(doc *field.Document) int {
+	if doc == nil {
+		return -1
+	}
+
+	for i, e := range doc.Entries {
+		if e.Kind == field.KindComment && strings.TrimSpace(e.Raw) == insertMarker {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// renderAtMarker returns the destination with the plan's block inserted just
+// above the marker, so successive runs stack up in order and the marker stays
+// put. Keys that are also defined below the marker go to a block at the end
+// instead: inserting them above would let the older value win on read.
+func (s *Service) renderAtMarker(plan Plan, marker int) (string, error) {
+	entries := s.dst.Doc.Entries
+
+	below := make(map[string]struct{})
+	for _, e := range entries[marker:] {
+		if e.Kind == field.KindVar {
+			below[e.Key] = struct{}{}
+		}
+	}
+
+	atMarker := Plan{Vars: make(map[string]string), Force: plan.Force}
+	atEnd := Plan{Vars: make(map[string]string), Force: plan.Force}
+	for k, v := range plan.Vars {
+		if _, ok := below[k]; ok {
+			atEnd.Vars[k] = v
+		} else {
+			atMarker.Vars[k] = v
+		}
+	}
+
+	// The block goes above the blank lines that separate the marker from
+	// the previous content, so that spacing is kept below the block.
+	insertAt := marker
+	for insertAt > 0 && entries[insertAt-1].Kind == field.KindBlank {
+		insertAt--
+	}
+
+	var b strings.Builder
+	for _, e := range entries[:insertAt] {
+		b.WriteString(e.Raw)
+	}
+	if err := s.Apply(atMarker, &b); err != nil {
+		return "", err
+	}
+	if len(atMarker.Vars) > 0 && insertAt == marker {
+		b.WriteString(s.eol())
+	}
+
+	for _, e := range entries[insertAt:] {
+		b.WriteString(e.Raw)
+	}
+	if len(atEnd.Vars) > 0 && !strings.HasSuffix(b.String(), "\n") {
+		b.WriteString(s.eol())
+	}
+	if err := s.Apply(atEnd, &b); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
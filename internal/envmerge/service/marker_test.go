@@ -0,0 +1,112 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Run_insertsAtMarker(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	dstPath := filepath.Join(tmpDir, ".env")
+
+	in := "# app\nPORT=80\n\n# envmerge:insert-here\n\n# local overrides\nDEBUG=1\n"
+	if err := os.WriteFile(dstPath, []byte(in), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	run := func(src map[string]string, force bool) {
+		t.Helper()
+
+		dst, err := readDstFile(tmpDir, ".env", parser{})
+		if err != nil {
+			t.Fatalf("readDstFile: %v", err)
+		}
+
+		s := &Service{force: force, src: src, dst: dst}
+		if err := s.Run(); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	}
+
+	run(map[string]string{"PORT": "80", "NAME": "app"}, false)
+	run(map[string]string{"PORT": "80", "NAME": "app", "CACHE": "on", "DEBUG": "0"}, true)
+
+	content := mustReadFile(t, dstPath)
+	lines := splitLines(content)
+
+	want := []string{
+		"# app",
+		"PORT=80",
+		"",
+		"# envmerge sync run: *",
+		"NAME=app",
+		"",
+		"# envmerge sync run (force): *",
+		"CACHE=on",
+		"",
+		"# envmerge:insert-here",
+		"",
+		"# local overrides",
+		"DEBUG=1",
+		"",
+		"# envmerge sync run (force): *",
+		"DEBUG=0",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines; want %d:\n%s", len(lines), len(want), content)
+	}
+	for i := range want {
+		if ok, _ := filepath.Match(want[i], lines[i]); !ok {
+			t.Fatalf("line %d = %q; want %q\n%s", i, lines[i], want[i], content)
+		}
+	}
+
+	got := mustParseFile(t, dstPath)
+	if !mapsEqual(got, map[string]string{"PORT": "80", "NAME": "app", "CACHE": "on", "DEBUG": "0"}) {
+		t.Fatalf("parsed %#v", got)
+	}
+}
+
+func Test_Run_noMarkerAppends(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	dstPath := filepath.Join(tmpDir, ".env")
+	if err := os.WriteFile(dstPath, []byte("A=1\n# envmerge:insert-here-not\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	dst, err := readDstFile(tmpDir, ".env", parser{})
+	if err != nil {
+		t.Fatalf("readDstFile: %v", err)
+	}
+
+	s := &Service{src: map[string]string{"B": "2"}, dst: dst}
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	lines := splitLines(mustReadFile(t, dstPath))
+	if lines[len(lines)-1] != "B=2" {
+		t.Fatalf("expected append at end, got %q", lines)
+	}
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			lines = append(lines, s[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		lines = append(lines, s[start:])
+	}
+
+	return lines
+}
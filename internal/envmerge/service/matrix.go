@@ -0,0 +1,124 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+// matrixSchemaVersion is bumped whenever the JSON matrix changes incompatibly.
+const matrixSchemaVersion = 1
+
+// Statuses of a source key in one destination of the --matrix report.
+// Excluded keys are filtered out of the sync (--exclude, --keys-file or the
+// destination's ignore list); protected ones differ, but a --force run would
+// still leave them alone (placeholder values, --respect-managed).
+const (
+	matrixPresent   = "present"
+	matrixMissing   = "missing"
+	matrixDiffers   = "differs"
+	matrixExcluded  = "excluded"
+	matrixProtected = "protected"
+)
+
+// matrix is the --matrix report: for every source key, its status in each
+// destination. It never holds values.
+type matrix struct {
+	SchemaVersion int         `json:"schema_version"`
+	Destinations  []string    `json:"destinations"`
+	Keys          []matrixRow `json:"keys"`
+}
+
+type matrixRow struct {
+	Key string `json:"key"`
+	// Status is parallel to Destinations.
+	Status []string `json:"status"`
+}
+
+// Matrix reports the status of every source key in each destination of the
+// comma-separated cfg.Dst, as a --force run would see it. Nothing is written;
+// the output follows cfg.Format.
+func Matrix(cfg config.Config, w io.Writer) error {
+	dsts := Destinations(cfg.Dst)
+	if len(dsts) == 0 {
+		return fmt.Errorf("--matrix needs at least one destination")
+	}
+
+	cfg.Force = true
+	m := matrix{SchemaVersion: matrixSchemaVersion, Destinations: dsts, Keys: []matrixRow{}}
+	rows := map[string]int{}
+	for i, dst := range dsts {
+		c := cfg
+		c.Dst = dst
+
+		s, err := New(c)
+		if err != nil {
+			return fmt.Errorf("%s: %w", dst, err)
+		}
+
+		for _, k := range sortedKeys(s.src) {
+			r, ok := rows[k]
+			if !ok {
+				r = len(m.Keys)
+				rows[k] = r
+				m.Keys = append(m.Keys, matrixRow{Key: k, Status: make([]string, len(dsts))})
+			}
+			m.Keys[r].Status[i] = s.matrixStatus(k)
+		}
+	}
+
+	sort.Slice(m.Keys, func(i, j int) bool { return m.Keys[i].Key < m.Keys[j].Key })
+
+	switch cfg.Format {
+	case "", "text":
+		return writeTextMatrix(w, m)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	default:
+		return fmt.Errorf("unknown output format %q (want text or json)", cfg.Format)
+	}
+}
+
+// matrixStatus is the status of the source key in s's destination, checked
+// in the order determineNewVars and determineUpdates apply.
+func (s *Service) matrixStatus(key string) string {
+	v := s.src[key]
+	old, inDst := s.dst.Data[key]
+
+	switch {
+	case !s.selected(key) || s.isIgnored(key):
+		return matrixExcluded
+	case !inDst:
+		return matrixMissing
+	case s.valuesEqual(old, v):
+		return matrixPresent
+	case !s.isManaged(key) || s.isPlaceholder(v):
+		return matrixProtected
+	default:
+		return matrixDiffers
+	}
+}
+
+// writeTextMatrix writes the matrix as an aligned table, one column per
+// destination.
+func writeTextMatrix(w io.Writer, m matrix) error {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "KEY\t%s\n", strings.Join(m.Destinations, "\t"))
+	for _, r := range m.Keys {
+		fmt.Fprintf(tw, "%s\t%s\n", r.Key, strings.Join(r.Status, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
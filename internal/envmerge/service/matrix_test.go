@@ -0,0 +1,117 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+func Test_Matrix(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	files := map[string]string{
+		".env.example": "API_URL=https://api\nDB_HOST=db\nNEW_KEY=1\n",
+		"a.env":        "API_URL=https://api\nDB_HOST=localhost\n",
+		"b.env":        "API_URL=https://api\nDB_HOST=db\nNEW_KEY=1\nLOCAL=x\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	a, b := filepath.Join(dir, "a.env"), filepath.Join(dir, "b.env")
+	cfg := config.Config{Src: filepath.Join(dir, ".env.example"), Dst: a + "," + b, MaxLineSize: 1024}
+
+	var out strings.Builder
+	if err := Matrix(cfg, &out); err != nil {
+		t.Fatalf("Matrix: %v", err)
+	}
+	var rows []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		rows = append(rows, strings.Join(strings.Fields(line), " "))
+	}
+	want := []string{
+		"KEY " + a + " " + b,
+		"API_URL present present",
+		"DB_HOST differs present",
+		"NEW_KEY missing present",
+	}
+	if strings.Join(rows, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("text matrix\ngot:  %q\nwant: %q", rows, want)
+	}
+
+	cfg.Format = "json"
+	out.Reset()
+	if err := Matrix(cfg, &out); err != nil {
+		t.Fatalf("Matrix json: %v", err)
+	}
+	var m matrix
+	if err := json.Unmarshal([]byte(out.String()), &m); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(m.Keys) != 3 || m.Keys[2].Key != "NEW_KEY" || strings.Join(m.Keys[2].Status, ",") != "missing,present" {
+		t.Fatalf("json matrix: %+v", m)
+	}
+
+	for name, content := range files {
+		if got := mustReadFile(t, filepath.Join(dir, name)); got != content {
+			t.Fatalf("%s was modified: %q", name, got)
+		}
+	}
+}
+
+func Test_Matrix_excludedAndProtected(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		".env.example": "APP_NAME=demo\nLOCAL_ONLY=1\nLOCAL_PATH=/src\nSECRET=changeme\nOWNED=new\nMANAGED=new\n",
+		"a.env":        "APP_NAME=demo\nLOCAL_PATH=/home\nSECRET=real\nOWNED=mine\n# envmerge:managed\nMANAGED=old\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	cfg := config.Config{
+		Src:                filepath.Join(dir, ".env.example"),
+		Dst:                filepath.Join(dir, "a.env"),
+		Exclude:            "LOCAL_*",
+		PlaceholderPattern: DefaultPlaceholderPattern,
+		RespectManaged:     true,
+		Format:             "json",
+	}
+
+	var out strings.Builder
+	if err := Matrix(cfg, &out); err != nil {
+		t.Fatalf("Matrix: %v", err)
+	}
+	var m matrix
+	if err := json.Unmarshal([]byte(out.String()), &m); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	got := map[string]string{}
+	for _, r := range m.Keys {
+		got[r.Key] = strings.Join(r.Status, ",")
+	}
+	want := map[string]string{
+		"APP_NAME":   matrixPresent,
+		"LOCAL_ONLY": matrixExcluded,
+		"LOCAL_PATH": matrixExcluded,
+		"SECRET":     matrixProtected,
+		"OWNED":      matrixProtected,
+		"MANAGED":    matrixDiffers,
+	}
+	if !mapsEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
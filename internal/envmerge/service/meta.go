@@ -0,0 +1,77 @@
+package service
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// metaPrefix starts a comment holding a JSON object of key metadata, such as
+// # @meta {"required":true,"group":"db"}.
+const metaPrefix = "@meta"
+
+// metaComment returns the JSON of a "# @meta {...}" comment line.
+func metaComment(line string) (string, bool) {
+	comment := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "#"))
+	rest, ok := strings.CutPrefix(comment, metaPrefix)
+	if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+		return "", false
+	}
+
+	return strings.TrimSpace(rest), true
+}
+
+// attachMeta parses the @meta comments above each var into its Meta; with
+// several, later fields win. A "type" field is also the entry's type unless a
+// "# type:NAME" hint set one. Malformed metadata is warned about and ignored.
+func (p parser) attachMeta(doc *field.Document) {
+	for i, e := range doc.Entries {
+		if e.Kind != field.KindVar {
+			continue
+		}
+
+		for j, c := range e.Doc {
+			raw, ok := metaComment(c)
+			if !ok {
+				continue
+			}
+
+			var meta map[string]any
+			if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+				// Doc is the run of comments right above the key.
+				line := e.Line - len(e.Doc) + j
+				slog.Default().Warn("ignoring malformed @meta comment", "path", p.file, "line", line, "key", e.Key, "error", err)
+				continue
+			}
+
+			if doc.Entries[i].Meta == nil {
+				doc.Entries[i].Meta = make(map[string]any, len(meta))
+			}
+			for k, v := range meta {
+				doc.Entries[i].Meta[k] = v
+			}
+		}
+
+		if t, ok := doc.Entries[i].Meta["type"].(string); ok && e.Type == "" {
+			doc.Entries[i].Type = t
+		}
+	}
+}
+
+// metaString returns the string field name of key's source metadata.
+func (s *Service) metaString(key, name string) string {
+	v, _ := s.srcEntries[key].Meta[name].(string)
+	return v
+}
+
+// groupOf returns the group --group-by-prefix puts key in: its metadata
+// "group" when set, otherwise its prefix.
+func (s *Service) groupOf(key string) string {
+	if g := s.metaString(key, "group"); g != "" {
+		return g
+	}
+
+	return keyGroup(key)
+}
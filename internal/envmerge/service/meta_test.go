@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_parser_meta(t *testing.T) {
+	t.Parallel()
+
+	content := "# Database host\n# @meta {\"required\":true,\"group\":\"db\"}\n# @meta {\"group\":\"storage\",\"type\":\"int\"}\nDB_PORT=5432\n" +
+		"# @meta {not json}\nBROKEN=1\n" +
+		"# @metadata {\"required\":true}\nNOT_META=1\n" +
+		"PLAIN=1\n"
+
+	vars := func(p parser) map[string]field.Entry {
+		t.Helper()
+		doc, err := p.document(strings.NewReader(content))
+		if err != nil {
+			t.Fatalf("document: %v", err)
+		}
+		return doc.Vars()
+	}
+
+	got := vars(parser{meta: true})
+	port := got["DB_PORT"]
+	if port.Meta["required"] != true || port.Meta["group"] != "storage" || port.Type != "int" {
+		t.Fatalf("DB_PORT: Meta = %v, Type = %q", port.Meta, port.Type)
+	}
+	for _, k := range []string{"BROKEN", "NOT_META", "PLAIN"} {
+		if got[k].Meta != nil {
+			t.Errorf("%s: Meta = %v, want none", k, got[k].Meta)
+		}
+	}
+
+	if got := vars(parser{}); got["DB_PORT"].Meta != nil || got["DB_PORT"].Type != "" {
+		t.Fatalf("metadata parsed while off: %+v", got["DB_PORT"])
+	}
+}
+
+func Test_meta_consumers(t *testing.T) {
+	t.Parallel()
+
+	doc, err := parser{meta: true}.document(strings.NewReader(
+		"# @meta {\"group\":\"db\"}\nAPP_DB=1\n# @meta {\"group\":\"db\"}\nZ_HOST=2\nAPP_NAME=3\n" +
+			"# the port\n# @meta {\"required\":false,\"type\":\"int\",\"description\":\"listen port\"}\nPORT=\n"))
+	if err != nil {
+		t.Fatalf("document: %v", err)
+	}
+	s := &Service{src: doc.Map(), srcEntries: doc.Vars(), groupByPrefix: true}
+
+	keys, _ := s.blockKeys(map[string]string{"APP_DB": "1", "Z_HOST": "2", "APP_NAME": "3"})
+	if strings.Join(keys, ",") != "APP_NAME,APP_DB,Z_HOST" {
+		t.Fatalf("block order = %v, want the db group together", keys)
+	}
+
+	m := s.buildManifest()
+	var port manifestKey
+	for _, k := range m.Keys {
+		if k.Key == "PORT" {
+			port = k
+		}
+	}
+	if port.Required || port.Type != "int" || port.Description != "listen port" {
+		t.Fatalf("PORT manifest entry = %+v", port)
+	}
+
+	s.validateTypes = true
+	if err := s.checkTypes(Plan{Vars: map[string]string{"PORT": "http"}}); !errors.Is(err, field.ErrTypeMismatch) {
+		t.Fatalf("checkTypes: err = %v, want ErrTypeMismatch from the metadata type", err)
+	}
+}
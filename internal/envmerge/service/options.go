@@ -0,0 +1,83 @@
+package service
+
+import "github.com/nuntiiscore/envmerge/internal/config"
+
+// Option adjusts the configuration of a Service built by Open. config.Config
+// is the full set of options; these cover the common ones, and any func of
+// this type can set the rest.
+type Option func(*config.Config)
+
+// DefaultConfig returns the configuration the command line starts from before
+// any flag is applied.
+func DefaultConfig() config.Config {
+	return config.Config{
+		Src:                   ".env.example",
+		Dst:                   ".env",
+		MaxLineSize:           1024 * 1024,
+		MinSecretLength:       DefaultMinSecretLength,
+		SecretPattern:         DefaultSecretPattern,
+		PlaceholderPattern:    DefaultPlaceholderPattern,
+		MergeCommentsFromDest: true,
+		Format:                "text",
+		DstFormat:             dstFormatEnv,
+		LineEnding:            "lf",
+		KeyPolicy:             keyPolicyStrict,
+		ControlChars:          controlCharsReject,
+		InteractiveFallback:   interactiveFallbackNone,
+		OutPerm:               "0600",
+		DstDuplicates:         dstDuplicatesLast,
+	}
+}
+
+// Open builds a Service merging src into dst, starting from DefaultConfig and
+// applying opts in order.
+func Open(src, dst string, opts ...Option) (*Service, error) {
+	cfg := DefaultConfig()
+	cfg.Src, cfg.Dst = src, dst
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return New(cfg)
+}
+
+// WithConfig replaces every option but the source and destination given to
+// Open with those of cfg, e.g. a configuration parsed from flags on top of
+// DefaultConfig.
+func WithConfig(cfg config.Config) Option {
+	return func(c *config.Config) {
+		cfg.Src, cfg.Dst = c.Src, c.Dst
+		*c = cfg
+	}
+}
+
+// WithForce also updates existing keys whose values differ.
+func WithForce() Option {
+	return func(cfg *config.Config) { cfg.Force = true }
+}
+
+// WithForceKeys updates existing keys listed in the file at path only.
+func WithForceKeys(path string) Option {
+	return func(cfg *config.Config) { cfg.ForceKeys = path }
+}
+
+// WithExclude skips keys matching the comma-separated globs; a leading !
+// re-includes.
+func WithExclude(patterns string) Option {
+	return func(cfg *config.Config) { cfg.Exclude = patterns }
+}
+
+// WithKeysFile syncs only the keys listed in the file at path.
+func WithKeysFile(path string) Option {
+	return func(cfg *config.Config) { cfg.KeysFile = path }
+}
+
+// WithDryRun prints the plan in format ("text" or "json") instead of writing.
+func WithDryRun(format string) Option {
+	return func(cfg *config.Config) { cfg.DryRun, cfg.Format = true, format }
+}
+
+// WithOut writes the merged result to path instead of the destination.
+func WithOut(path string) Option {
+	return func(cfg *config.Config) { cfg.Out = path }
+}
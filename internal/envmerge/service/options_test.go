@@ -0,0 +1,56 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+func Test_Open(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, ".env.example")
+	dst := filepath.Join(dir, ".env")
+	if err := os.WriteFile(src, []byte("A=new\nB=2\nSKIP=1\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(dst, []byte("A=old\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	s, err := Open(src, dst, WithForce(), WithExclude("SKIP"), func(cfg *config.Config) { cfg.Summary = true })
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	got := mustReadFile(t, dst)
+	for _, want := range []string{"A=new\n", "B=2\n", "# envmerge: "} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("destination lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "SKIP") {
+		t.Fatalf("excluded key written:\n%s", got)
+	}
+}
+
+func Test_WithConfig(t *testing.T) {
+	t.Parallel()
+
+	flags := DefaultConfig()
+	flags.Src, flags.Dst, flags.Force = "ignored.example", "ignored.env", true
+
+	cfg := DefaultConfig()
+	cfg.Src, cfg.Dst = "a.example", "a.env"
+	WithConfig(flags)(&cfg)
+	if cfg.Src != "a.example" || cfg.Dst != "a.env" || !cfg.Force || cfg.OutPerm != "0600" {
+		t.Fatalf("got %+v", cfg)
+	}
+}
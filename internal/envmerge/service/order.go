@@ -0,0 +1,46 @@
+package service
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// orderHintPrefix starts a source comment like "# envmerge:order A,B,C" that
+// puts those keys first in sync blocks.
+const orderHintPrefix = "# envmerge:order"
+
+// orderHint collects the keys of every order hint comment in doc, in order.
+// Keys that src does not define are dropped with a warning.
+func orderHint(doc *field.Document, src map[string]string) []string {
+	var keys, unknown []string
+	for _, e := range doc.Entries {
+		if e.Kind != field.KindComment {
+			continue
+		}
+
+		list, ok := strings.CutPrefix(strings.TrimSpace(e.Raw), orderHintPrefix)
+		if !ok || (list != "" && list[0] != ' ' && list[0] != '\t') {
+			continue
+		}
+
+		for _, k := range strings.Split(list, ",") {
+			k = strings.TrimSpace(k)
+			if k == "" {
+				continue
+			}
+			if _, ok := src[k]; !ok {
+				unknown = append(unknown, k)
+				continue
+			}
+			keys = append(keys, k)
+		}
+	}
+
+	if len(unknown) > 0 {
+		slog.Default().Warn("order hint names keys missing from source", "keys", unknown)
+	}
+
+	return keys
+}
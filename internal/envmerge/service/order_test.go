@@ -0,0 +1,58 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_orderHint(t *testing.T) {
+	t.Parallel()
+
+	doc, err := parseDocument(strings.NewReader(
+		"# envmerge:order C, A,GONE\nA=1\nB=2\nC=3\n#envmerge:order B\n# envmerge:ordering D\nD=4\n# envmerge:order D\n"))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+
+	got := orderHint(doc, doc.Map())
+	if strings.Join(got, ",") != "C,A,D" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func Test_writeBlock_orderHint(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]string{"A": "1", "B": "2", "C": "3", "DB_HOST": "h", "DB_PORT": "p", "Z": "26"}
+	cases := []struct {
+		name          string
+		groupByPrefix bool
+		want          string
+	}{
+		{name: "hinted first", want: "Z=26\nC=3\nA=1\nB=2\nDB_HOST=h\nDB_PORT=p"},
+		{name: "with groups", groupByPrefix: true, want: "Z=26\nC=3\nA=1\n\nB=2\n\nDB_HOST=h\nDB_PORT=p"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Service{
+				orderHint:     []string{"Z", "C", "MISSING", "A", "Z"},
+				groupByPrefix: tc.groupByPrefix,
+				dst:           &field.File{Data: map[string]string{}},
+			}
+
+			var b strings.Builder
+			if err := s.writeBlock(&b, vars, false); err != nil {
+				t.Fatalf("writeBlock: %v", err)
+			}
+
+			if got := strings.Join(splitLines(b.String())[2:], "\n"); got != tc.want {
+				t.Fatalf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
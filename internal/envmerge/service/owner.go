@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// fileOwner is the --owner that created files are handed to; -1 leaves the
+// user or group unchanged.
+type fileOwner struct {
+	uid, gid int
+}
+
+// parseOwner reads "user:group", "user" or ":group", by name or numeric id.
+func parseOwner(spec string) (*fileOwner, error) {
+	name, group, _ := strings.Cut(spec, ":")
+	owner := &fileOwner{uid: -1, gid: -1}
+
+	if name != "" {
+		id, err := lookupID(name, func(n string) (string, error) {
+			u, err := user.Lookup(n)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unknown owner user %q: %w", name, err)
+		}
+		owner.uid = id
+	}
+
+	if group != "" {
+		id, err := lookupID(group, func(n string) (string, error) {
+			g, err := user.LookupGroup(n)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unknown owner group %q: %w", group, err)
+		}
+		owner.gid = id
+	}
+
+	if owner.uid < 0 && owner.gid < 0 {
+		return nil, fmt.Errorf("invalid owner %q (want user:group)", spec)
+	}
+
+	return owner, nil
+}
+
+// lookupID returns name as a number if it is one, otherwise the id lookup
+// finds for it.
+func lookupID(name string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+
+	id, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(id)
+}
+
+// fileCreated reports whether path does not exist yet, so the open about to
+// follow creates it.
+func fileCreated(path string) bool {
+	_, err := os.Stat(path)
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// chownCreated hands a file envmerge just created to owner. Lacking the
+// privilege is not fatal: the file stays as it is and a warning is logged.
+func (s *Service) chownCreated(path string) {
+	if s.owner == nil {
+		return
+	}
+	if runtime.GOOS == "windows" {
+		slog.Default().Info("--owner is not supported on Windows, skipping", "path", path)
+		return
+	}
+
+	if err := os.Chown(path, s.owner.uid, s.owner.gid); err != nil {
+		slog.Default().Warn("cannot change owner of created file", "path", path, "error", err)
+	}
+}
@@ -0,0 +1,90 @@
+//go:build unix
+
+package service
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_parseOwner(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		spec    string
+		want    fileOwner
+		wantErr bool
+	}{
+		{spec: "1000:1001", want: fileOwner{uid: 1000, gid: 1001}},
+		{spec: "1000", want: fileOwner{uid: 1000, gid: -1}},
+		{spec: ":1001", want: fileOwner{uid: -1, gid: 1001}},
+		{spec: ":", wantErr: true},
+		{spec: "no-such-user-envmerge:0", wantErr: true},
+		{spec: "0:no-such-group-envmerge", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseOwner(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseOwner(%q): expected error, got %+v", tt.spec, *got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseOwner(%q): %v", tt.spec, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("parseOwner(%q) = %+v, want %+v", tt.spec, *got, tt.want)
+		}
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		t.Skipf("no current user: %v", err)
+	}
+	got, err := parseOwner(u.Username)
+	if err != nil {
+		t.Fatalf("parseOwner(%q): %v", u.Username, err)
+	}
+	if strconv.Itoa(got.uid) != u.Uid {
+		t.Errorf("parseOwner(%q).uid = %d, want %s", u.Username, got.uid, u.Uid)
+	}
+}
+
+func Test_openDst_owner(t *testing.T) {
+	t.Parallel()
+
+	// Handing the file to its current owner works unprivileged too.
+	owner := &fileOwner{uid: os.Getuid(), gid: os.Getgid()}
+	path := filepath.Join(t.TempDir(), ".env")
+	s := &Service{dst: &field.File{Path: path}, owner: owner}
+	if err := s.openDst(); err != nil {
+		t.Fatalf("openDst: %v", err)
+	}
+	_ = s.dst.Dsc.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	st := info.Sys().(*syscall.Stat_t)
+	if int(st.Uid) != owner.uid || int(st.Gid) != owner.gid {
+		t.Fatalf("owner = %d:%d, want %d:%d", st.Uid, st.Gid, owner.uid, owner.gid)
+	}
+
+	// An owner we may not hand files to only warns.
+	path = filepath.Join(t.TempDir(), ".env")
+	s = &Service{dst: &field.File{Path: path}, owner: &fileOwner{uid: 1 << 30, gid: -1}}
+	if err := s.openDst(); err != nil {
+		t.Fatalf("openDst unprivileged: %v", err)
+	}
+	_ = s.dst.Dsc.Close()
+}
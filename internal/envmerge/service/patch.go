@@ -0,0 +1,192 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// patchContext is the number of unchanged lines around each hunk.
+const patchContext = 3
+
+// writePatch writes the run's change to the destination as a unified diff,
+// to stdout when patchPath is "-", without modifying the destination. The
+// result applies with patch -p1 or git apply.
+func (s *Service) writePatch(plan Plan) error {
+	before := s.dst.Doc.String()
+	after, err := s.renderResult(plan)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.ToSlash(s.dstName)
+	if name == "" {
+		name = filepath.Base(s.dst.Path)
+	}
+	// Only a file that does not exist yet is created by the patch; one with
+	// nothing but comments or blank lines is patched like any other.
+	oldName := "a/" + name
+	if fileCreated(s.dst.Path) {
+		oldName = "/dev/null"
+	}
+	patch := unifiedDiff(oldName, "b/"+name, before, after)
+
+	if s.patchPath == "-" {
+		w := s.stdout
+		if w == nil {
+			w = os.Stdout
+		}
+		_, err := io.WriteString(w, patch)
+		return err
+	}
+
+	slog.Default().Info("Writing file", "path", s.patchPath)
+	if err := os.WriteFile(s.patchPath, []byte(patch), 0o644); err != nil {
+		return fmt.Errorf("error writing patch: %w", err)
+	}
+
+	return nil
+}
+
+// renderResult returns the destination as it reads once plan is applied.
+func (s *Service) renderResult(plan Plan) (string, error) {
+	switch {
+	case s.managedRegion:
+		if len(plan.Vars) == 0 {
+			return s.dst.Doc.String(), nil
+		}
+		return s.renderManaged(plan)
+	case s.template != nil && len(s.dst.Doc.Entries) == 0 && len(plan.Vars) > 0:
+		return s.renderTemplate(plan)
+	default:
+		return s.render(plan)
+	}
+}
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// unifiedDiff returns the unified diff turning a into b, or "" when they are
+// equal. Lines keep their own terminators, so CRLF files diff correctly.
+func unifiedDiff(oldName, newName, a, b string) string {
+	ops := diffLines(splitAfterLines(a), splitAfterLines(b))
+
+	// oldPos and newPos count the lines of each side before ops[i].
+	oldPos := make([]int, len(ops)+1)
+	newPos := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldPos[i+1], newPos[i+1] = oldPos[i], newPos[i]
+		if op.kind != '+' {
+			oldPos[i+1]++
+		}
+		if op.kind != '-' {
+			newPos[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Hunks closer than twice the context merge into one.
+		end := i
+		for j := i; j < len(ops) && j-end <= 2*patchContext; j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			}
+		}
+		start, stop := max(i-patchContext, 0), min(end+patchContext+1, len(ops))
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(oldPos[start], oldPos[stop]-oldPos[start]),
+			hunkRange(newPos[start], newPos[stop]-newPos[start]))
+		for _, op := range ops[start:stop] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		i = stop
+	}
+
+	return out.String()
+}
+
+// hunkRange formats one side of a hunk header; an empty side names the line
+// before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// diffLines computes a minimal line diff from the longest common subsequence.
+// Env files are small, so the quadratic table is fine.
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+
+	return ops
+}
+
+// splitAfterLines splits s into lines that keep their terminators.
+func splitAfterLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_unifiedDiff(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{name: "equal", a: "A=1\n", b: "A=1\n", want: ""},
+		{
+			name: "append with context",
+			a:    "1\n2\n3\n4\n5\n",
+			b:    "1\n2\n3\n4\n5\nA=1\n",
+			want: "--- a/.env\n+++ b/.env\n@@ -3,3 +3,4 @@\n 3\n 4\n 5\n+A=1\n",
+		},
+		{
+			name: "change in the middle",
+			a:    "A=1\nB=2\nC=3\n",
+			b:    "A=1\nB=20\nC=3\n",
+			want: "--- a/.env\n+++ b/.env\n@@ -1,3 +1,3 @@\n A=1\n-B=2\n+B=20\n C=3\n",
+		},
+		{
+			name: "distant changes split hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			want: "--- a/.env\n+++ b/.env\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n" +
+				"@@ -8,3 +9,4 @@\n 8\n 9\n 10\n+11\n",
+		},
+		{
+			name: "missing newline at end",
+			a:    "A=1",
+			b:    "A=1\nB=2\n",
+			want: "--- a/.env\n+++ b/.env\n@@ -1 +1,2 @@\n-A=1\n\\ No newline at end of file\n+A=1\n+B=2\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := unifiedDiff("a/.env", "b/.env", tc.a, tc.b); got != tc.want {
+				t.Fatalf("got\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_Run_patchLeavesDestinationUntouched(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		existing string
+		want     string
+	}{
+		{
+			name:     "existing destination",
+			existing: "A=1\n",
+			want:     "--- a/.env\n+++ b/.env\n@@ -1 +1,4 @@\n A=1\n+\n+# envmerge sync run: <ts>\n+B=2\n",
+		},
+		{
+			name:     "comments only",
+			existing: "# local settings\n",
+			want:     "--- a/.env\n+++ b/.env\n@@ -1 +1,5 @@\n # local settings\n+\n+# envmerge sync run: <ts>\n+A=1\n+B=2\n",
+		},
+		{
+			name: "new destination",
+			want: "--- /dev/null\n+++ b/.env\n@@ -0,0 +1,4 @@\n+\n+# envmerge sync run: <ts>\n+A=1\n+B=2\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			dstPath := filepath.Join(tmpDir, ".env")
+			if tc.existing != "" {
+				if err := os.WriteFile(dstPath, []byte(tc.existing), 0o644); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+			}
+			dst, err := readDstFile(tmpDir, ".env", parser{})
+			if err != nil {
+				t.Fatalf("readDstFile: %v", err)
+			}
+
+			patchPath := filepath.Join(tmpDir, "envmerge.patch")
+			s := &Service{
+				src:       map[string]string{"A": "1", "B": "2"},
+				dst:       dst,
+				dstName:   ".env",
+				patchPath: patchPath,
+			}
+			if err := s.Run(); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			lines := strings.Split(mustReadFile(t, patchPath), "\n")
+			for i, l := range lines {
+				if strings.HasPrefix(l, "+# envmerge sync run: ") {
+					lines[i] = "+# envmerge sync run: <ts>"
+				}
+			}
+			if got := strings.Join(lines, "\n"); got != tc.want {
+				t.Fatalf("got\n%s\nwant\n%s", got, tc.want)
+			}
+
+			content, err := os.ReadFile(dstPath)
+			if tc.existing == "" {
+				if !os.IsNotExist(err) {
+					t.Fatalf("destination should not be created, err=%v", err)
+				}
+			} else if string(content) != tc.existing {
+				t.Fatalf("destination changed: %q", content)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// defaultOutPerm is the mode of a created --out file unless --out-perm says
+// otherwise; the merged result may hold secrets.
+const defaultOutPerm fs.FileMode = 0o600
+
+// parsePerm reads an octal file mode such as "0644" or "644".
+func parsePerm(spec string) (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(spec, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid --out-perm %q (want an octal mode such as 0644)", spec)
+	}
+
+	return fs.FileMode(mode), nil
+}
+
+// outFileMode is the mode a created --out file gets.
+func (s *Service) outFileMode() fs.FileMode {
+	if s.outPerm == 0 {
+		return defaultOutPerm
+	}
+
+	return s.outPerm
+}
+
+// chmodCreated sets a just created --out file to its mode exactly, which the
+// umask would otherwise narrow. A failure is logged, not fatal.
+func (s *Service) chmodCreated(path string) {
+	if err := os.Chmod(path, s.outFileMode()); err != nil {
+		slog.Default().Warn("cannot change mode of created file", "path", path, "error", err)
+	}
+}
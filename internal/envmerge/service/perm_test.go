@@ -0,0 +1,84 @@
+//go:build unix
+
+package service
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+func Test_parsePerm(t *testing.T) {
+	t.Parallel()
+
+	for spec, want := range map[string]fs.FileMode{"0644": 0o644, "600": 0o600, "0": 0} {
+		if got, err := parsePerm(spec); err != nil || got != want {
+			t.Errorf("parsePerm(%q) = %o, %v; want %o", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"rw-r--r--", "0888", "01777", ""} {
+		if _, err := parsePerm(spec); err == nil {
+			t.Errorf("parsePerm(%q) should fail", spec)
+		}
+	}
+}
+
+func Test_Run_outPerm(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		outPerm  string
+		existing bool
+		want     fs.FileMode
+	}{
+		{name: "default", want: defaultOutPerm},
+		{name: "explicit", outPerm: "0644", want: 0o644},
+		{name: "beyond umask", outPerm: "0666", want: 0o666},
+		{name: "existing keeps its mode", outPerm: "0644", existing: true, want: 0o640},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			srcPath := filepath.Join(tmpDir, ".env.example")
+			dstPath := filepath.Join(tmpDir, ".env")
+			outPath := filepath.Join(tmpDir, ".env.out")
+			if err := os.WriteFile(srcPath, []byte("A=1\n"), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if err := os.WriteFile(dstPath, []byte("B=2\n"), 0o600); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if tt.existing {
+				if err := os.WriteFile(outPath, nil, 0o640); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+			}
+
+			s, err := New(config.Config{Src: srcPath, Dst: dstPath, Out: outPath, OutPerm: tt.outPerm})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if err := s.Run(); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			info, err := os.Stat(outPath)
+			if err != nil {
+				t.Fatalf("stat: %v", err)
+			}
+			if got := info.Mode().Perm(); got != tt.want {
+				t.Fatalf("mode = %o, want %o", got, tt.want)
+			}
+			if info, _ := os.Stat(dstPath); info.Mode().Perm() != 0o600 {
+				t.Fatalf("destination mode changed to %o", info.Mode().Perm())
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// pipeRequest is what Pipe reads: the source and destination contents plus
+// options that override the ones Pipe was started with.
+type pipeRequest struct {
+	Src     string      `json:"src"`
+	Dst     string      `json:"dst"`
+	Options pipeOptions `json:"options"`
+}
+
+type pipeOptions struct {
+	Force     bool     `json:"force"`
+	ForceKeys []string `json:"force_keys"`
+	Exclude   string   `json:"exclude"`
+	Summary   bool     `json:"summary"`
+	DryRun    bool     `json:"dry_run"`
+}
+
+// pipeResponse carries the plan and, unless the request was a dry run, the
+// merged destination. On failure only Error is set.
+type pipeResponse struct {
+	Plan   *jsonPlan `json:"plan,omitempty"`
+	Result *string   `json:"result,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Pipe reads one JSON request from r and writes one JSON response to w, so
+// tools in other languages can drive parsing and merging without files. A
+// failed request is reported in the response and returned as well.
+func Pipe(cfg config.Config, r io.Reader, w io.Writer) error {
+	resp, err := servePipe(cfg, r)
+	if err != nil {
+		resp = pipeResponse{Error: err.Error()}
+	}
+
+	if encErr := json.NewEncoder(w).Encode(resp); encErr != nil {
+		return fmt.Errorf("error writing response: %w", encErr)
+	}
+
+	return err
+}
+
+func servePipe(cfg config.Config, r io.Reader) (pipeResponse, error) {
+	var req pipeRequest
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return pipeResponse{}, fmt.Errorf("error decoding request: %w", err)
+	}
+
+	cfg.Force = cfg.Force || req.Options.Force
+	cfg.Summary = cfg.Summary || req.Options.Summary
+	if req.Options.Exclude != "" {
+		cfg.Exclude = req.Options.Exclude
+	}
+	// The request's keys replace a --force-keys file.
+	if len(req.Options.ForceKeys) > 0 {
+		cfg.ForceKeys = ""
+	}
+	if cfg.ThreeWay {
+		return pipeResponse{}, fmt.Errorf("--three-way is not supported in pipe mode")
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return pipeResponse{}, fmt.Errorf("cannot determine caller dir: %w", err)
+	}
+
+	s, err := configure(cfg, dir)
+	if err != nil {
+		return pipeResponse{}, err
+	}
+	if len(req.Options.ForceKeys) > 0 {
+		if cfg.AddOnly {
+			return pipeResponse{}, errAddOnlyForce
+		}
+		s.forceKeys = make(map[string]struct{}, len(req.Options.ForceKeys))
+		for _, k := range req.Options.ForceKeys {
+			if cfg.UpperKeys {
+				k = strings.ToUpper(k)
+			}
+			s.forceKeys[k] = struct{}{}
+		}
+	}
+
+	p := parserFor(cfg)
+	srcDoc, err := p.document(strings.NewReader(req.Src))
+	if err != nil {
+		return pipeResponse{}, fmt.Errorf("error reading source: %w", err)
+	}
+	dstDoc, err := p.document(strings.NewReader(req.Dst))
+	if err != nil {
+		return pipeResponse{}, fmt.Errorf("error reading destination: %w", err)
+	}
+
+	if err := s.prepare(cfg, dir, p, srcDoc, &field.File{Data: dstDoc.Map(), Doc: dstDoc}); err != nil {
+		return pipeResponse{}, err
+	}
+
+	// The checks Run makes before writing, minus the ones about files.
+	if s.failOnDestOnly {
+		if keys := s.destOnlyKeys(); len(keys) > 0 {
+			return pipeResponse{}, fmt.Errorf("%w: %s", field.ErrDestOnlyKeys, strings.Join(keys, ", "))
+		}
+	}
+	if err := s.checkDeprecated(); err != nil {
+		return pipeResponse{}, err
+	}
+	plan := s.Plan()
+	if s.validateTypes {
+		if err := s.checkTypes(plan); err != nil {
+			return pipeResponse{}, err
+		}
+	}
+	if err := s.checkParseWarnings(); err != nil {
+		return pipeResponse{}, err
+	}
+	if err := s.checkChanges(plan); err != nil {
+		return pipeResponse{}, err
+	}
+
+	jp := s.buildPlan(plan.Vars)
+	resp := pipeResponse{Plan: &jp}
+	if cfg.DryRun || req.Options.DryRun {
+		return resp, nil
+	}
+
+	result, err := s.renderResult(plan)
+	if err != nil {
+		return pipeResponse{}, err
+	}
+	resp.Result = &result
+
+	return resp, nil
+}
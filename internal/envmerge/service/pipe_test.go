@@ -0,0 +1,147 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+func Test_Pipe(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		req        string
+		wantAdded  []string
+		wantUpdate []string
+		wantResult string
+		wantErr    bool
+	}{
+		{
+			name:       "append missing",
+			req:        `{"src": "A=1\nB=2\n", "dst": "A=1\n"}`,
+			wantAdded:  []string{"B"},
+			wantResult: "A=1\n\n# envmerge sync run: <ts>\nB=2\n",
+		},
+		{
+			name:       "force keys",
+			req:        `{"src": "A=new\nB=new\n", "dst": "A=old\nB=old\n", "options": {"force_keys": ["B"]}}`,
+			wantUpdate: []string{"B"},
+			wantResult: "A=old\nB=old\n\n# envmerge sync run (force): <ts>\nB=new\n",
+		},
+		{
+			name:      "dry run",
+			req:       `{"src": "A=1\n", "dst": "", "options": {"dry_run": true}}`,
+			wantAdded: []string{"A"},
+		},
+		{name: "bad json", req: `{"src": `, wantErr: true},
+		{name: "unknown option", req: `{"src": "", "dst": "", "options": {"nope": true}}`, wantErr: true},
+		{name: "bad source", req: `{"src": "A", "dst": ""}`, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out strings.Builder
+			err := Pipe(config.Config{}, strings.NewReader(tc.req), &out)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tc.wantErr)
+			}
+
+			var resp pipeResponse
+			if err := json.Unmarshal([]byte(out.String()), &resp); err != nil {
+				t.Fatalf("response is not JSON: %v\n%s", err, out.String())
+			}
+
+			if tc.wantErr {
+				if resp.Error == "" || resp.Plan != nil || resp.Result != nil {
+					t.Fatalf("expected only an error, got %s", out.String())
+				}
+				return
+			}
+
+			var added, updated []string
+			for _, v := range resp.Plan.Added {
+				added = append(added, v.Key)
+			}
+			for _, v := range resp.Plan.Updated {
+				updated = append(updated, v.Key)
+			}
+			if strings.Join(added, ",") != strings.Join(tc.wantAdded, ",") || strings.Join(updated, ",") != strings.Join(tc.wantUpdate, ",") {
+				t.Fatalf("plan added %v updated %v", added, updated)
+			}
+
+			if tc.wantResult == "" {
+				if resp.Result != nil {
+					t.Fatalf("dry run must not return a result: %q", *resp.Result)
+				}
+				return
+			}
+
+			lines := strings.Split(*resp.Result, "\n")
+			for i, l := range lines {
+				if strings.HasPrefix(l, "# envmerge sync run") {
+					head, _, _ := strings.Cut(l, ": ")
+					lines[i] = head + ": <ts>"
+				}
+			}
+			if got := strings.Join(lines, "\n"); got != tc.wantResult {
+				t.Fatalf("result %q, want %q", got, tc.wantResult)
+			}
+		})
+	}
+}
+
+func Test_Pipe_addOnly(t *testing.T) {
+	t.Parallel()
+
+	for _, req := range []string{
+		`{"src": "A=new\n", "dst": "A=old\n", "options": {"force": true}}`,
+		`{"src": "A=new\n", "dst": "A=old\n", "options": {"force_keys": ["A"]}}`,
+	} {
+		var out strings.Builder
+		if err := Pipe(config.Config{AddOnly: true}, strings.NewReader(req), &out); !errors.Is(err, errAddOnlyForce) {
+			t.Fatalf("%s: expected errAddOnlyForce, got %v", req, err)
+		}
+	}
+}
+
+func Test_Pipe_cliOptions(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	mapPath := filepath.Join(tmpDir, "values.map")
+	renamePath := filepath.Join(tmpDir, "renames.txt")
+	for path, content := range map[string]string{mapPath: "DB_HOST=localhost -> db\n", renamePath: "OLD_NAME=NEW_NAME\n"} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	cfg := config.Config{UpperKeys: true, MapFile: mapPath, RenameFile: renamePath}
+	req := `{"src": "db_host=localhost\nnew_name=example\nport=2\n", "dst": "PORT=1\nOLD_NAME=local\n", "options": {"force_keys": ["port"]}}`
+
+	var out strings.Builder
+	if err := Pipe(cfg, strings.NewReader(req), &out); err != nil {
+		t.Fatalf("Pipe: %v\n%s", err, out.String())
+	}
+	var resp pipeResponse
+	if err := json.Unmarshal([]byte(out.String()), &resp); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+
+	got, err := parseDocument(strings.NewReader(*resp.Result))
+	if err != nil {
+		t.Fatalf("result: %v", err)
+	}
+	want := map[string]string{"PORT": "2", "OLD_NAME": "local", "DB_HOST": "db", "NEW_NAME": "local"}
+	if !mapsEqual(got.Map(), want) {
+		t.Fatalf("got %v, want %v", got.Map(), want)
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// planSchemaVersion is bumped whenever the JSON plan changes incompatibly.
+const planSchemaVersion = 1
+
+const maskedValue = "***"
+
+// DefaultSecretPattern lists the key globs whose values are masked in output.
+const DefaultSecretPattern = "*SECRET*,*PASSWORD*,*PASSWD*,*TOKEN*,*PRIVATE*,*CREDENTIAL*,*API_KEY*,*_KEY"
+
+type planValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type planUpdate struct {
+	Key string `json:"key"`
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// jsonPlan is the machine-readable dry-run output. Every list is sorted and
+// the plan carries no timestamps, so it diffs cleanly between runs.
+type jsonPlan struct {
+	SchemaVersion int          `json:"schema_version"`
+	Added         []planValue  `json:"added"`
+	Updated       []planUpdate `json:"updated"`
+	Unchanged     []string     `json:"unchanged"`
+	DestOnly      []string     `json:"dest_only"`
+}
+
+func (s *Service) buildPlan(written map[string]string) jsonPlan {
+	p := jsonPlan{
+		SchemaVersion: planSchemaVersion,
+		Added:         []planValue{},
+		Updated:       []planUpdate{},
+		Unchanged:     []string{},
+		DestOnly:      s.destOnlyKeys(),
+	}
+	if p.DestOnly == nil {
+		p.DestOnly = []string{}
+	}
+
+	for _, k := range sortedKeys(s.src) {
+		newValue, isWritten := written[k]
+		oldValue, inDst := s.dst.Data[k]
+
+		switch {
+		case isWritten && inDst:
+			p.Updated = append(p.Updated, planUpdate{Key: k, Old: s.mask(k, oldValue), New: s.mask(k, newValue)})
+		case isWritten:
+			p.Added = append(p.Added, planValue{Key: k, Value: s.mask(k, newValue)})
+		case inDst:
+			p.Unchanged = append(p.Unchanged, k)
+		}
+	}
+
+	return p
+}
+
+func (s *Service) printPlan(written map[string]string) error {
+	w := s.stdout
+	if w == nil {
+		w = os.Stdout
+	}
+
+	p := s.buildPlan(written)
+	switch s.format {
+	case "", "text":
+		return writeTextPlan(w, p, s.previewLines)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(p)
+	default:
+		return fmt.Errorf("unknown output format %q (want text or json)", s.format)
+	}
+}
+
+// printEffective writes the environment the destination will hold after
+// plan, one sorted KEY=value line per key, masking secrets when maskSecrets
+// is set.
+func (s *Service) printEffective(plan Plan) error {
+	w := s.stdout
+	if w == nil {
+		w = os.Stdout
+	}
+
+	env := s.effectiveEnv(plan)
+
+	var b strings.Builder
+	for _, k := range sortedKeys(env) {
+		v := env[k]
+		if s.maskSecrets {
+			v = s.mask(k, v)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", k, formatEnvValue(v))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// effectiveEnv returns the key/value pairs the destination will hold after
+// plan.
+func (s *Service) effectiveEnv(plan Plan) map[string]string {
+	env := make(map[string]string, len(s.dst.Data)+len(plan.Vars))
+	for k, v := range s.dst.Data {
+		env[k] = v
+	}
+	for k, v := range plan.Vars {
+		env[k] = v
+	}
+
+	return env
+}
+
+func writeTextPlan(w io.Writer, p jsonPlan, limit int) error {
+	var lines []string
+	for _, v := range p.Added {
+		lines = append(lines, fmt.Sprintf("+ %s=%s", v.Key, formatEnvValue(v.Value)))
+	}
+	for _, v := range p.Updated {
+		lines = append(lines, fmt.Sprintf("~ %s: %s -> %s", v.Key, formatEnvValue(v.Old), formatEnvValue(v.New)))
+	}
+
+	return writeTextLines(w, lines, limit)
+}
+
+// writeTextLines writes the lines of a text plan, or "no changes" when there
+// are none. A positive limit is the --preview-lines cap: the lines past it are
+// only counted in a footer.
+func writeTextLines(w io.Writer, lines []string, limit int) error {
+	if len(lines) == 0 {
+		_, err := io.WriteString(w, "no changes\n")
+		return err
+	}
+
+	var b strings.Builder
+	for i, line := range lines {
+		if limit > 0 && i == limit {
+			fmt.Fprintf(&b, "... and %d more\n", len(lines)-limit)
+			break
+		}
+		b.WriteString(line + "\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (s *Service) isSecret(key string) bool {
+	return !s.secrets.included(strings.ToUpper(key))
+}
+
+func (s *Service) mask(key, value string) string {
+	if s.isSecret(key) {
+		return maskedValue
+	}
+
+	return value
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
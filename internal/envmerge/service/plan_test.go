@@ -0,0 +1,303 @@
+package service
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_printPlan_json(t *testing.T) {
+	t.Parallel()
+
+	secrets, err := parseKeyFilter(DefaultSecretPattern)
+	if err != nil {
+		t.Fatalf("parseKeyFilter: %v", err)
+	}
+
+	var out bytes.Buffer
+	s := &Service{
+		force:   true,
+		format:  "json",
+		secrets: secrets,
+		stdout:  &out,
+		src: map[string]string{
+			"PORT":        "8080",
+			"DB_PASSWORD": "s3cret",
+			"NAME":        "app",
+			"HOST":        "new",
+		},
+		dst: &field.File{Data: map[string]string{
+			"HOST":     "old",
+			"NAME":     "app",
+			"LEFTOVER": "1",
+		}},
+	}
+
+	if err := s.printPlan(s.determineUpdates()); err != nil {
+		t.Fatalf("printPlan: %v", err)
+	}
+
+	want := `{
+  "schema_version": 1,
+  "added": [
+    {
+      "key": "DB_PASSWORD",
+      "value": "***"
+    },
+    {
+      "key": "PORT",
+      "value": "8080"
+    }
+  ],
+  "updated": [
+    {
+      "key": "HOST",
+      "old": "old",
+      "new": "new"
+    }
+  ],
+  "unchanged": [
+    "NAME"
+  ],
+  "dest_only": [
+    "LEFTOVER"
+  ]
+}
+`
+	if out.String() != want {
+		t.Fatalf("json plan mismatch\ngot:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func Test_printPlan_jsonEmptyListsAreArrays(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	s := &Service{format: "json", stdout: &out, dst: &field.File{Data: map[string]string{}}}
+
+	if err := s.printPlan(nil); err != nil {
+		t.Fatalf("printPlan: %v", err)
+	}
+
+	want := "{\n  \"schema_version\": 1,\n  \"added\": [],\n  \"updated\": [],\n  \"unchanged\": [],\n  \"dest_only\": []\n}\n"
+	if out.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func Test_Run_dryRunWritesNothing(t *testing.T) {
+	t.Parallel()
+
+	dstPath := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(dstPath, []byte("A=old\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	f, err := os.OpenFile(dstPath, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("openfile: %v", err)
+	}
+	defer f.Close()
+
+	var out bytes.Buffer
+	s := &Service{
+		force:  true,
+		dryRun: true,
+		stdout: &out,
+		src:    map[string]string{"A": "new", "B": "two words"},
+		dst:    &field.File{Dsc: f, Data: map[string]string{"A": "old"}},
+	}
+
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if got := mustReadFile(t, dstPath); got != "A=old\n" {
+		t.Fatalf("dry run modified dst: %q", got)
+	}
+
+	want := "+ B=\"two words\"\n~ A: old -> new\n"
+	if out.String() != want {
+		t.Fatalf("text plan=%q; want %q", out.String(), want)
+	}
+}
+
+func Test_Run_printEffective(t *testing.T) {
+	t.Parallel()
+
+	secrets, err := parseKeyFilter(DefaultSecretPattern)
+	if err != nil {
+		t.Fatalf("parseKeyFilter: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		mask bool
+		want string
+	}{
+		{name: "plain", want: "A=new\nB=\"two words\"\nDB_PASSWORD=hunter2\nLOCAL=1\n"},
+		{name: "masked", mask: true, want: "A=new\nB=\"two words\"\nDB_PASSWORD=***\nLOCAL=1\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dstPath := filepath.Join(t.TempDir(), ".env")
+			if err := os.WriteFile(dstPath, []byte("A=old\nLOCAL=1\nDB_PASSWORD=hunter2\n"), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			f, err := os.OpenFile(dstPath, os.O_RDWR|os.O_APPEND, 0o644)
+			if err != nil {
+				t.Fatalf("openfile: %v", err)
+			}
+			defer f.Close()
+
+			var out bytes.Buffer
+			s := &Service{
+				force:       true,
+				effective:   true,
+				maskSecrets: tc.mask,
+				secrets:     secrets,
+				stdout:      &out,
+				src:         map[string]string{"A": "new", "B": "two words"},
+				dst:         &field.File{Dsc: f, Data: map[string]string{"A": "old", "LOCAL": "1", "DB_PASSWORD": "hunter2"}},
+			}
+
+			if err := s.Run(); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			if got := mustReadFile(t, dstPath); got != "A=old\nLOCAL=1\nDB_PASSWORD=hunter2\n" {
+				t.Fatalf("print-effective modified dst: %q", got)
+			}
+			if out.String() != tc.want {
+				t.Fatalf("got %q, want %q", out.String(), tc.want)
+			}
+		})
+	}
+}
+
+func Test_PlanApply_inMemoryDestinations(t *testing.T) {
+	t.Parallel()
+
+	s := &Service{
+		src: map[string]string{"A": "1", "B": "2"},
+		dst: &field.File{Data: map[string]string{"A": "1"}},
+	}
+
+	plan := s.Plan()
+	if plan.Force || !mapsEqual(plan.Vars, map[string]string{"B": "2"}) {
+		t.Fatalf("unexpected plan: %#v", plan)
+	}
+
+	var first, second bytes.Buffer
+	if err := s.Apply(plan, &first); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	plan.Vars["C"] = "added by caller"
+	if err := s.Apply(plan, &second); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if !bytes.Contains(first.Bytes(), []byte("\nB=2\n")) || bytes.Contains(first.Bytes(), []byte("C=")) {
+		t.Fatalf("first destination: %q", first.String())
+	}
+	if !bytes.Contains(second.Bytes(), []byte("\nB=2\nC=\"added by caller\"\n")) {
+		t.Fatalf("second destination: %q", second.String())
+	}
+}
+
+func Test_Apply_emptyPlanWritesNothing(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	s := &Service{dst: &field.File{Data: map[string]string{}}}
+	if err := s.Apply(Plan{Vars: map[string]string{}, Force: true}, &out); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if out.Len() != 0 {
+		t.Fatalf("expected no output, got %q", out.String())
+	}
+}
+
+func Test_printPlan_previewLines(t *testing.T) {
+	t.Parallel()
+
+	src := map[string]string{"A": "1", "B": "2", "C": "3", "D": "4"}
+	cases := []struct {
+		name  string
+		limit int
+		want  string
+	}{
+		{name: "all", limit: 0, want: "+ A=1\n+ B=2\n+ C=3\n+ D=4\n"},
+		{name: "truncated", limit: 2, want: "+ A=1\n+ B=2\n... and 2 more\n"},
+		{name: "limit above size", limit: 10, want: "+ A=1\n+ B=2\n+ C=3\n+ D=4\n"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			s := &Service{previewLines: tc.limit, stdout: &out, src: src, dst: &field.File{Data: map[string]string{}}}
+			if err := s.printPlan(s.determineNewVars()); err != nil {
+				t.Fatalf("printPlan: %v", err)
+			}
+			if out.String() != tc.want {
+				t.Fatalf("got %q, want %q", out.String(), tc.want)
+			}
+		})
+	}
+
+	// The JSON plan is never truncated.
+	var out bytes.Buffer
+	s := &Service{previewLines: 1, format: "json", stdout: &out, src: src, dst: &field.File{Data: map[string]string{}}}
+	if err := s.printPlan(s.determineNewVars()); err != nil {
+		t.Fatalf("printPlan: %v", err)
+	}
+	if n := strings.Count(out.String(), `"key"`); n != 4 {
+		t.Fatalf("json plan has %d keys, want 4", n)
+	}
+}
+
+func Test_isSecret_lowercasePattern(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, ".env.example")
+	if err := os.WriteFile(srcPath, []byte("api_token=abc\nport=80\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	var out bytes.Buffer
+	s, err := New(config.Config{
+		Src:           srcPath,
+		Dst:           filepath.Join(tmpDir, ".env"),
+		SecretPattern: "*token*",
+		DryRun:        true,
+		Format:        "json",
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	s.stdout = &out
+
+	if !s.isSecret("api_token") || s.isSecret("port") {
+		t.Fatalf("isSecret: api_token=%v port=%v", s.isSecret("api_token"), s.isSecret("port"))
+	}
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := out.String(); strings.Contains(got, "abc") {
+		t.Fatalf("secret printed in plain text: %s", got)
+	}
+}
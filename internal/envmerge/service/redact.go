@@ -0,0 +1,45 @@
+package service
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// redactedLine replaces a malformed line kept by lenient parsing, which may
+// hold a secret nobody can tell apart from its key.
+const redactedLine = "# *** (malformed line redacted)"
+
+// Redact writes a copy of the destination to redactOut with every secret
+// value masked. Comments, blank lines, order and the other values are kept
+// byte for byte; malformed lines lenient parsing skipped are replaced by
+// redactedLine. The destination itself is only read.
+func (s *Service) Redact() error {
+	defer func() {
+		if s.dst != nil && s.dst.Dsc != nil {
+			_ = s.dst.Dsc.Close()
+		}
+	}()
+
+	var b strings.Builder
+	for _, e := range s.dst.Doc.Entries {
+		if e.Kind == field.KindVar && s.isSecret(e.Key) {
+			e = s.compactEntry(e, maskedValue)
+		}
+		if isTolerated(e) {
+			e.Raw = redactedLine + e.Raw[len(strings.TrimRight(e.Raw, "\r\n")):]
+		}
+		b.WriteString(e.Raw)
+	}
+
+	slog.Default().Info("Writing file", "path", s.redactOut)
+	if err := os.WriteFile(s.redactOut, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("error writing redacted copy: %w", err)
+	}
+
+	slog.Default().Info("dotenv redacted", "path", s.redactOut)
+	return nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Redact(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	dstPath := filepath.Join(tmpDir, ".env")
+	content := "# database\nDB_HOST=db\nDB_PASSWORD=hunter2\n\n" +
+		"  export API_TOKEN=\"abc def\"\nPRIVATE_KEY=\"-----BEGIN-----\nxyz\n-----END-----\"\nPORT=8080\n" +
+		"SECRET_KEY=\"abc\" # rotated monthly\n"
+	if err := os.WriteFile(dstPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	dst, err := readDstFile(tmpDir, ".env", parser{})
+	if err != nil {
+		t.Fatalf("readDstFile: %v", err)
+	}
+	secrets, err := parseKeyFilter(DefaultSecretPattern)
+	if err != nil {
+		t.Fatalf("parseKeyFilter: %v", err)
+	}
+
+	outPath := filepath.Join(tmpDir, "redacted.env")
+	s := &Service{dst: dst, secrets: secrets, redactOut: outPath}
+	if err := s.Redact(); err != nil {
+		t.Fatalf("Redact: %v", err)
+	}
+
+	want := "# database\nDB_HOST=db\nDB_PASSWORD=***\n\n" +
+		"  export API_TOKEN=\"***\"\nPRIVATE_KEY=\"***\"\nPORT=8080\n" +
+		"SECRET_KEY=\"***\" # rotated monthly\n"
+	if got := mustReadFile(t, outPath); got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+	if got := mustReadFile(t, dstPath); got != content {
+		t.Fatalf("destination changed: %q", got)
+	}
+}
+
+func Test_Redact_malformedLines(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	dstPath := filepath.Join(tmpDir, ".env")
+	if err := os.WriteFile(dstPath, []byte("A=1\nPASSWORD hunter2\r\nB=\"x\" hunter2\nC=3"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	dst, err := readDstFile(tmpDir, ".env", parser{lenient: true})
+	if err != nil {
+		t.Fatalf("readDstFile: %v", err)
+	}
+	secrets, err := parseKeyFilter(DefaultSecretPattern)
+	if err != nil {
+		t.Fatalf("parseKeyFilter: %v", err)
+	}
+
+	outPath := filepath.Join(tmpDir, "redacted.env")
+	s := &Service{dst: dst, secrets: secrets, redactOut: outPath}
+	if err := s.Redact(); err != nil {
+		t.Fatalf("Redact: %v", err)
+	}
+
+	want := "A=1\n" + redactedLine + "\r\n" + redactedLine + "\nC=3"
+	if got := mustReadFile(t, outPath); got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// Markers of the managed region that --managed-region confines reads and
+// writes to.
+const (
+	regionStart = "# >>> envmerge managed >>>"
+	regionEnd   = "# <<< envmerge managed <<<"
+)
+
+// managedRegion returns the indexes of the region's start and end markers in
+// doc, or -1, -1 when the region is absent.
+func managedRegion(doc *field.Document) (int, int, error) {
+	start, end := -1, -1
+	for i, e := range doc.Entries {
+		if e.Kind != field.KindComment {
+			continue
+		}
+
+		switch strings.TrimSpace(e.Raw) {
+		case regionStart:
+			if start >= 0 {
+				return 0, 0, fmt.Errorf("duplicate managed region start on line %d", e.Line)
+			}
+			start = i
+		case regionEnd:
+			if start < 0 || end >= 0 {
+				return 0, 0, fmt.Errorf("unexpected managed region end on line %d", e.Line)
+			}
+			end = i
+		}
+	}
+
+	if start >= 0 && end < 0 {
+		return 0, 0, fmt.Errorf("managed region started on line %d is not closed", doc.Entries[start].Line)
+	}
+
+	return start, end, nil
+}
+
+// regionData returns the vars defined inside the managed region.
+func regionData(doc *field.Document) (map[string]string, error) {
+	start, end, err := managedRegion(doc)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 {
+		return map[string]string{}, nil
+	}
+
+	inner := field.Document{Entries: doc.Entries[start+1 : end]}
+	return inner.Map(), nil
+}
+
+// renderManaged returns the destination with plan's block appended at the end
+// of the managed region, adding the region at the end of the file if it is
+// missing. Everything outside the region is kept byte for byte.
+func (s *Service) renderManaged(plan Plan) (string, error) {
+	start, end, err := managedRegion(s.dst.Doc)
+	if err != nil {
+		return "", err
+	}
+
+	entries := s.dst.Doc.Entries
+
+	var b strings.Builder
+	if start < 0 {
+		b.WriteString(s.dst.Doc.String())
+		if b.Len() > 0 {
+			if !strings.HasSuffix(b.String(), "\n") {
+				b.WriteString(s.eol())
+			}
+			b.WriteString(s.eol())
+		}
+		b.WriteString(regionStart + s.eol())
+		if err := s.Apply(plan, &b); err != nil {
+			return "", err
+		}
+		b.WriteString(regionEnd + s.eol())
+
+		return b.String(), nil
+	}
+
+	for _, e := range entries[:end] {
+		b.WriteString(e.Raw)
+	}
+	if err := s.Apply(plan, &b); err != nil {
+		return "", err
+	}
+	for _, e := range entries[end:] {
+		b.WriteString(e.Raw)
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Run_managedRegion(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "existing region",
+			in:   "# mine\nPORT=1\r\n\n" + regionStart + "\nA=1\n" + regionEnd + "\n# tail  \n",
+			want: "# mine\nPORT=1\r\n\n" + regionStart + "\nA=1\n\n# envmerge sync run: <ts>\nPORT=80\n" + regionEnd + "\n# tail  \n",
+		},
+		{
+			name: "region created",
+			in:   "# mine\nPORT=1",
+			want: "# mine\nPORT=1\n\n" + regionStart + "\n\n# envmerge sync run: <ts>\nA=1\nPORT=80\n" + regionEnd + "\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			dstPath := filepath.Join(tmpDir, ".env")
+			if err := os.WriteFile(dstPath, []byte(tc.in), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			dst, err := readDstFile(tmpDir, ".env", parser{})
+			if err != nil {
+				t.Fatalf("readDstFile: %v", err)
+			}
+			if dst.Data, err = regionData(dst.Doc); err != nil {
+				t.Fatalf("regionData: %v", err)
+			}
+
+			s := &Service{
+				managedRegion: true,
+				src:           map[string]string{"A": "1", "PORT": "80"},
+				dst:           dst,
+			}
+			if err := s.Run(); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			lines := strings.Split(mustReadFile(t, dstPath), "\n")
+			for i, l := range lines {
+				if strings.HasPrefix(l, "# envmerge sync run: ") {
+					lines[i] = "# envmerge sync run: <ts>"
+				}
+			}
+			if got := strings.Join(lines, "\n"); got != tc.want {
+				t.Fatalf("got  %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_managedRegion_malformed(t *testing.T) {
+	t.Parallel()
+
+	for _, in := range []string{
+		regionStart + "\nA=1\n",
+		regionEnd + "\n" + regionStart + "\n",
+		regionStart + "\n" + regionStart + "\n" + regionEnd + "\n",
+	} {
+		doc, err := parseDocument(strings.NewReader(in))
+		if err != nil {
+			t.Fatalf("parseDocument: %v", err)
+		}
+		if _, err := regionData(doc); err == nil {
+			t.Fatalf("expected error for %q", in)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// parseRenames turns OLD=NEW specs into a map from the new key to the old
+// one, which is the direction the merge looks them up in.
+func parseRenames(specs []string) (map[string]string, error) {
+	renames := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		oldKey, newKey, ok := strings.Cut(spec, "=")
+		oldKey, newKey = strings.TrimSpace(oldKey), strings.TrimSpace(newKey)
+		if !ok || oldKey == "" || newKey == "" {
+			return nil, fmt.Errorf("invalid rename %q: want OLD=NEW", spec)
+		}
+		if prev, ok := renames[newKey]; ok && prev != oldKey {
+			return nil, fmt.Errorf("conflicting renames to %q: %q and %q", newKey, prev, oldKey)
+		}
+
+		renames[newKey] = oldKey
+	}
+
+	return renames, nil
+}
+
+func readRenamesFile(dir, file string) ([]string, error) {
+	filePath := resolvePath(dir, file)
+	slog.Default().Info("Reading file", "path", filePath)
+
+	content, err := os.Open(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, field.ErrFileDoesNotExist
+		}
+		return nil, fmt.Errorf("open %q: %w", filePath, err)
+	}
+	defer content.Close()
+
+	specs, err := renameList(content)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %q: %w", filePath, err)
+	}
+
+	return specs, nil
+}
+
+// renameList reads newline-delimited OLD=NEW specs, skipping blanks and
+// comments.
+func renameList(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+
+	var specs []string
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		specs = append(specs, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning file: %w", err)
+	}
+
+	return specs, nil
+}
+
+// carryRenamed gives keys that are new under their renamed name the value the
+// destination still holds under the old name.
+func (s *Service) carryRenamed(vars map[string]string) {
+	for newKey := range vars {
+		if _, ok := s.dst.Data[newKey]; ok {
+			continue
+		}
+		oldKey, ok := s.renames[newKey]
+		if !ok {
+			continue
+		}
+		if v, ok := s.dst.Data[oldKey]; ok {
+			vars[newKey] = v
+		}
+	}
+}
+
+// dropRenamed removes the old-name definitions from the destination document
+// once the new name is defined there or in vars. It reports whether anything
+// was removed.
+func (s *Service) dropRenamed(vars map[string]string) bool {
+	stale := make(map[string]struct{})
+	for newKey, oldKey := range s.renames {
+		_, inDst := s.dst.Data[newKey]
+		_, inPlan := vars[newKey]
+		if _, ok := s.dst.Data[oldKey]; ok && (inDst || inPlan) {
+			stale[oldKey] = struct{}{}
+		}
+	}
+	if len(stale) == 0 {
+		return false
+	}
+
+	kept := s.dst.Doc.Entries[:0:0]
+	for _, e := range s.dst.Doc.Entries {
+		if _, ok := stale[e.Key]; ok && e.Kind == field.KindVar {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	s.dst.Doc.Entries = kept
+
+	for oldKey := range stale {
+		delete(s.dst.Data, oldKey)
+	}
+
+	return true
+}
+
+// isRenamedFrom reports whether key is the old name of a rename.
+func (s *Service) isRenamedFrom(key string) bool {
+	for _, oldKey := range s.renames {
+		if oldKey == key {
+			return true
+		}
+	}
+
+	return false
+}
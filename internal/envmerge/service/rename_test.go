@@ -0,0 +1,86 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_parseRenames(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseRenames([]string{"OLD_NAME=NEW_NAME", " A = B "})
+	if err != nil {
+		t.Fatalf("parseRenames: %v", err)
+	}
+	if !mapsEqual(got, map[string]string{"NEW_NAME": "OLD_NAME", "B": "A"}) {
+		t.Fatalf("got %#v", got)
+	}
+
+	for _, spec := range []string{"OLD", "=NEW", "OLD="} {
+		if _, err := parseRenames([]string{spec}); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+	if _, err := parseRenames([]string{"A=NEW", "B=NEW"}); err == nil {
+		t.Fatal("expected error for conflicting renames")
+	}
+}
+
+func Test_Run_renameCarriesExistingValue(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		remove bool
+		want   map[string]string
+	}{
+		{
+			name: "old key kept",
+			want: map[string]string{"A": "1", "OLD_NAME": "local", "NEW_NAME": "local", "C": "3"},
+		},
+		{
+			name:   "old key removed",
+			remove: true,
+			want:   map[string]string{"A": "1", "NEW_NAME": "local", "C": "3"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			dstPath := filepath.Join(tmpDir, ".env")
+			if err := os.WriteFile(dstPath, []byte("A=1\nOLD_NAME=local\n"), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			dst, err := readDstFile(tmpDir, ".env", parser{})
+			if err != nil {
+				t.Fatalf("readDstFile: %v", err)
+			}
+
+			s := &Service{
+				renames:        map[string]string{"NEW_NAME": "OLD_NAME"},
+				removeRenamed:  tc.remove,
+				failOnDestOnly: true,
+				src:            map[string]string{"A": "1", "NEW_NAME": "example", "C": "3"},
+				dst:            dst,
+			}
+			if err := s.Run(); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			if got := mustParseFile(t, dstPath); !mapsEqual(got, tc.want) {
+				t.Fatalf("got %#v, want %#v", got, tc.want)
+			}
+
+			content := mustReadFile(t, dstPath)
+			if !strings.HasPrefix(content, "A=1\n") || strings.Count(content, "# envmerge sync run") != 1 {
+				t.Fatalf("unexpected content:\n%s", content)
+			}
+		})
+	}
+}
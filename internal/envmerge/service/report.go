@@ -0,0 +1,69 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// runReport is the structured summary of a run written by --report-file.
+type runReport struct {
+	Timestamp   string   `json:"timestamp"`
+	Source      string   `json:"source"`
+	Destination string   `json:"destination"`
+	Added       []string `json:"added"`
+	Updated     []string `json:"updated"`
+}
+
+func (s *Service) buildReport(plan Plan, now time.Time) runReport {
+	r := runReport{
+		Timestamp:   now.Format(time.RFC3339),
+		Source:      s.srcName,
+		Destination: s.dstName,
+		Added:       []string{},
+		Updated:     []string{},
+	}
+
+	for k := range plan.Vars {
+		if _, ok := s.dst.Data[k]; ok {
+			r.Updated = append(r.Updated, k)
+		} else {
+			r.Added = append(r.Added, k)
+		}
+	}
+	sort.Strings(r.Added)
+	sort.Strings(r.Updated)
+
+	return r
+}
+
+// writeReport stores the run summary in the report file: replaced with an
+// indented JSON document, or appended as one JSON line in append mode.
+func (s *Service) writeReport(plan Plan) error {
+	r := s.buildReport(plan, time.Now())
+
+	if !s.reportAppend {
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding report: %w", err)
+		}
+
+		return os.WriteFile(s.reportFile, append(data, '\n'), 0o644)
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("error encoding report: %w", err)
+	}
+
+	f, err := os.OpenFile(s.reportFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open %q: %w", s.reportFile, err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_buildReport(t *testing.T) {
+	t.Parallel()
+
+	s := &Service{
+		srcName: ".env.example",
+		dstName: ".env",
+		dst:     &field.File{Data: map[string]string{"A": "old"}},
+	}
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	got := s.buildReport(Plan{Vars: map[string]string{"A": "new", "C": "3", "B": "2"}}, now)
+
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	want := `{"timestamp":"2024-01-02T03:04:05Z","source":".env.example","destination":".env","added":["B","C"],"updated":["A"]}`
+	if string(data) != want {
+		t.Fatalf("got  %s\nwant %s", data, want)
+	}
+}
+
+func Test_writeReport_overwriteAndAppend(t *testing.T) {
+	t.Parallel()
+
+	reportPath := filepath.Join(t.TempDir(), "envmerge-report.json")
+	s := &Service{
+		reportFile: reportPath,
+		dst:        &field.File{Data: map[string]string{}},
+	}
+	plan := Plan{Vars: map[string]string{"A": "1"}}
+
+	for i := 0; i < 2; i++ {
+		if err := s.writeReport(plan); err != nil {
+			t.Fatalf("writeReport: %v", err)
+		}
+	}
+
+	var r runReport
+	if err := json.Unmarshal([]byte(mustReadFile(t, reportPath)), &r); err != nil {
+		t.Fatalf("overwrite mode should leave a single JSON document: %v", err)
+	}
+
+	_ = os.Remove(reportPath)
+	s.reportAppend = true
+	for i := 0; i < 2; i++ {
+		if err := s.writeReport(plan); err != nil {
+			t.Fatalf("writeReport: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(mustReadFile(t, reportPath)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 report lines, got %d:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+	for _, line := range lines {
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", line, err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// DefaultMinSecretLength is the --min-secret-length below which a secret is
+// reported as too short.
+const DefaultMinSecretLength = 16
+
+// weakSecret is a destination secret that looks unset or easy to guess.
+type weakSecret struct {
+	Key    string
+	Reason string
+}
+
+// weakSecrets checks every secret key of the destination: empty, shorter
+// than minSecretLength, a placeholder, or still the source's example value.
+func (s *Service) weakSecrets() []weakSecret {
+	var weak []weakSecret
+	for _, k := range sortedKeys(s.dst.Data) {
+		if !s.isSecret(k) {
+			continue
+		}
+
+		v := s.dst.Data[k]
+		example, inSrc := s.src[k]
+
+		var reason string
+		switch {
+		case v == "":
+			reason = "empty"
+		case inSrc && v == example:
+			reason = "same as the source example"
+		case s.isPlaceholder(v):
+			reason = "placeholder"
+		case len(v) < s.minSecretLength:
+			reason = fmt.Sprintf("shorter than %d characters", s.minSecretLength)
+		default:
+			continue
+		}
+
+		weak = append(weak, weakSecret{Key: k, Reason: reason})
+	}
+
+	return weak
+}
+
+// CheckSecrets reports weak destination secrets as KEY<TAB>reason lines,
+// never printing their values, and fails when there are any. Nothing is
+// written to the destination.
+func (s *Service) CheckSecrets() error {
+	defer func() {
+		if s.dst != nil && s.dst.Dsc != nil {
+			_ = s.dst.Dsc.Close()
+		}
+	}()
+
+	w := s.stdout
+	if w == nil {
+		w = os.Stdout
+	}
+
+	weak := s.weakSecrets()
+
+	var b strings.Builder
+	keys := make([]string, len(weak))
+	for i, ws := range weak {
+		fmt.Fprintf(&b, "%s\t%s\n", ws.Key, ws.Reason)
+		keys[i] = ws.Key
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+
+	if len(weak) > 0 {
+		return fmt.Errorf("%w: %s", field.ErrWeakSecrets, strings.Join(keys, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_CheckSecrets(t *testing.T) {
+	t.Parallel()
+
+	secrets, err := parseKeyFilter(DefaultSecretPattern)
+	if err != nil {
+		t.Fatalf("parseKeyFilter: %v", err)
+	}
+
+	var out strings.Builder
+	s := &Service{
+		secrets:         secrets,
+		placeholder:     regexp.MustCompile(DefaultPlaceholderPattern),
+		minSecretLength: 8,
+		stdout:          &out,
+		src: map[string]string{
+			"API_TOKEN":   "example-token-123",
+			"DB_PASSWORD": "changeme",
+		},
+		dst: &field.File{Data: map[string]string{
+			"API_TOKEN":     "example-token-123",
+			"DB_PASSWORD":   "changeme",
+			"EMPTY_SECRET":  "",
+			"SHORT_SECRET":  "abc",
+			"STRONG_SECRET": "f9a8c3e1d2b4",
+			"PORT":          "1",
+		}},
+	}
+
+	err = s.CheckSecrets()
+	if !errors.Is(err, field.ErrWeakSecrets) {
+		t.Fatalf("expected ErrWeakSecrets, got %v", err)
+	}
+
+	want := "API_TOKEN\tsame as the source example\n" +
+		"DB_PASSWORD\tsame as the source example\n" +
+		"EMPTY_SECRET\tempty\n" +
+		"SHORT_SECRET\tshorter than 8 characters\n"
+	if got := out.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+	for _, v := range []string{"example-token-123", "changeme", "abc"} {
+		if strings.Contains(out.String()+err.Error(), v) {
+			t.Fatalf("output leaks value %q", v)
+		}
+	}
+
+	s.dst.Data = map[string]string{"DB_PASSWORD": "your-password-here", "STRONG_SECRET": "f9a8c3e1d2b4"}
+	out.Reset()
+	if err := s.CheckSecrets(); !errors.Is(err, field.ErrWeakSecrets) || out.String() != "DB_PASSWORD\tplaceholder\n" {
+		t.Fatalf("placeholder: err=%v out=%q", err, out.String())
+	}
+
+	s.dst.Data = map[string]string{"STRONG_SECRET": "f9a8c3e1d2b4"}
+	out.Reset()
+	if err := s.CheckSecrets(); err != nil || out.String() != "" {
+		t.Fatalf("strong secrets: err=%v out=%q", err, out.String())
+	}
+}
@@ -2,45 +2,578 @@ package service
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/nuntiiscore/envmerge/internal/config"
 	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
 )
 
+// DefaultPlaceholderPattern matches common example-file placeholders such as
+// "changeme", "your-key-here" or "xxx".
+const DefaultPlaceholderPattern = `(?i)^(changeme|change[-_]me|your[-_].*[-_]here|x{3,}|todo|tbd|<[^>]*>)$`
+
 type Service struct {
-	force bool
-	src   map[string]string
-	dst   *field.File
+	force       bool
+	forceKeys   map[string]struct{}
+	placeholder *regexp.Regexp
+	exclude     keyFilter
+	// onlyKeys, when set, limits syncing to the keys of the --keys-file.
+	onlyKeys    map[string]struct{}
+	summary     bool
+	warnSimilar bool
+	// respectManaged limits force updates to destination keys annotated
+	// with managedAnnotation.
+	respectManaged bool
+	// valueCaseInsensitive makes force mode treat values that differ only
+	// by case, like true and TRUE, as equal.
+	valueCaseInsensitive bool
+	// validateTypes makes Run fail, without writing, when a merged value
+	// does not match the type hinted in the source.
+	validateTypes bool
+	// maxChanges, when positive, makes a force run that would change more
+	// keys fail without writing, unless confirmLarge is set.
+	maxChanges   int
+	confirmLarge bool
+	// failOnDestOnly makes Run fail, without writing, when dst has keys
+	// that src does not.
+	failOnDestOnly bool
+	// renames maps a new key name to its old one; removeRenamed deletes
+	// the old definition from dst once the new one exists.
+	renames       map[string]string
+	removeRenamed bool
+	srcName       string
+	dstName       string
+	src           map[string]string
+	dst           *field.File
+	// srcEntries holds the parsed source entry behind each src value.
+	srcEntries map[string]field.Entry
+	// valueMap rewrites src values before they are compared or written.
+	valueMap *valueMap
+
+	// outPath is set when the merged result goes to a file other than dst;
+	// out is its handle while Run writes to it.
+	outPath string
+	out     *os.File
+	// owner, when set, receives the files envmerge creates.
+	owner *fileOwner
+	// outPerm is the mode of a created --out file; existing files keep theirs.
+	outPerm fs.FileMode
+
+	// parseWarnings are the lines lenient parsing skipped; with
+	// failOnParseWarning, Run fails on them before writing.
+	parseWarnings      []parseWarning
+	failOnParseWarning bool
+	// previewLines caps the entries of a text plan or diff; 0 prints all.
+	previewLines int
+	// targetExistingOnly refuses to write into a destination without keys,
+	// which is meant to be filled by hand, unless init opts in.
+	targetExistingOnly bool
+	init               bool
+	// normalizeBooleans writes boolean-ish values as true or false, and
+	// treats spellings of the same boolean as equal.
+	normalizeBooleans bool
+	// githubOutput is the GitHub Actions step output file, from
+	// $GITHUB_OUTPUT; a run appends its counts to it.
+	githubOutput string
+	// addOnly guarantees that existing values are never updated: plans only
+	// add missing keys, and force options are rejected.
+	addOnly bool
+	// failOnDeprecated makes Run fail, without writing, while dst still
+	// holds keys the source marks as deprecated.
+	failOnDeprecated bool
+	// checksum makes Run print a hash of the merged environment instead of
+	// writing it.
+	checksum bool
+	// ignored are the keys the destination marks "# envmerge:ignore"; they
+	// are never added or updated.
+	ignored map[string]struct{}
+	// interactive makes Run ask about every change on stdin before writing;
+	// interactiveFallback applies all or none of them when stdin is not a
+	// terminal.
+	interactive         bool
+	interactiveFallback string
+	stdin               io.Reader
+	stdinIsTerminal     bool
+	// trace makes Run log why each source key is or is not written.
+	trace bool
+	// blankValues makes Run print the missing keys as KEY= instead of
+	// writing them.
+	blankValues bool
+
+	// dstFormat is dstFormatJSON for a JSON destination, which is rewritten
+	// whole; jsonLiterals are its keys holding non-string values.
+	dstFormat    string
+	jsonLiterals map[string]struct{}
+
+	// managedRegion confines reading and writing dst to the region between
+	// the managed markers.
+	managedRegion bool
+
+	lineEnding  string
+	shellArrays bool
+	// orderHint lists the keys of the source's "# envmerge:order" comments;
+	// they are written first, in that order.
+	orderHint []string
+	// groupByPrefix orders a block's keys by their first underscore
+	// segment and puts a blank line between groups.
+	groupByPrefix      bool
+	collapseWhitespace bool
+	decodeEscapes      bool
+	// stripExport keeps the export prefix out of the output, both on
+	// written keys and on existing destination lines.
+	stripExport bool
+
+	// carryComments writes the source comment above each key in the sync
+	// block; mergeCommentsFromDest limits that to keys new to dst.
+	carryComments         bool
+	mergeCommentsFromDest bool
+
+	// template lays out a new, empty destination; see renderTemplate.
+	template *field.Document
+	// ensureGitignore lists the written file in the .gitignore beside it.
+	ensureGitignore bool
+
+	reportFile   string
+	reportAppend bool
+
+	auditLog   string
+	auditActor string
+
+	// manifestPath receives a JSON description of the source keys on
+	// every run.
+	manifestPath string
+
+	// lock keeps value hashes in dst's .lock file to spot local edits
+	// between runs.
+	lock bool
+	// threeWay merges against ancestor, the lock's hashes from the last sync,
+	// instead of forcing; conflictsOut receives the keys it cannot resolve.
+	threeWay     bool
+	ancestor     lockFile
+	conflictsOut string
+	// backup copies the destination to backupDir, or next to it when empty,
+	// before the run first writes it; backedUp records that it has.
+	backup    bool
+	backupDir string
+	backedUp  bool
+	// dstStat is the destination's size and modification time before it was
+	// read, kept with --check-unmodified to detect concurrent writers.
+	dstStat *cachedFile
+	// cacheConfig is the configuration a run that changes nothing records in
+	// its --cache file; nil when the run cannot be cached.
+	cacheConfig *config.Config
+
+	dryRun bool
+	format string
+	// redactOut receives a copy of dst with secret values masked.
+	redactOut string
+	// patchPath receives the run's change as a unified diff instead of
+	// the destination; "-" is stdout.
+	patchPath string
+	secrets   keyFilter
+	// minSecretLength is the shortest secret CheckSecrets accepts.
+	minSecretLength int
+	// since is the --since time ListSince filters sync blocks by.
+	since time.Time
+	// effective prints the merged environment instead of writing it;
+	// maskSecrets masks secret values in that output.
+	effective   bool
+	maskSecrets bool
+	stdout      io.Writer
+
+	parseDuration time.Duration
+	bytesWritten  int
+	// dstRewritten records that a rewrite changed the destination's bytes,
+	// which a run can do without adding or updating keys.
+	dstRewritten bool
+}
+
+func New(cfg config.Config) (*Service, error) {
+	return newService(cfg, nil)
+}
+
+// NewFromFS is like New but reads the source from fsys (e.g. an embed.FS),
+// with cfg.Src as a slash-separated path inside it. The destination is still
+// read from and written to the OS filesystem.
+func NewFromFS(fsys fs.FS, cfg config.Config) (*Service, error) {
+	return newService(cfg, fsys)
 }
 
-func New(src, dst string, force bool) (*Service, error) {
+func newService(cfg config.Config, srcFS fs.FS) (*Service, error) {
 	dir, err := os.Getwd()
 	if err != nil {
 		return nil, fmt.Errorf("cannot determine caller dir: %w", err)
 	}
 
-	srcContent, err := readSrcFile(dir, src)
+	p := parserFor(cfg)
+	parseStart := time.Now()
+
+	s, err := configure(cfg, dir)
 	if err != nil {
-		return nil, fmt.Errorf("error reading source file: %w", err)
+		return nil, err
+	}
+
+	srcDoc := &field.Document{}
+	if merges(cfg) {
+		srcDoc, err = readSources(dir, srcFS, cfg, p)
+		if err != nil {
+			return nil, fmt.Errorf("error reading source file: %w", err)
+		}
+	}
+
+	if cfg.CheckUnmodified {
+		// Taken before reading, so a change made while parsing counts too.
+		stat, err := statFile(resolvePath(dir, cfg.Dst))
+		if err != nil {
+			return nil, fmt.Errorf("error reading destination file: %w", err)
+		}
+		s.dstStat = &stat
 	}
 
-	dstFile, err := readDstFile(dir, dst)
+	var dstFile *field.File
+	if s.dstFormat == dstFormatJSON {
+		dstFile, s.jsonLiterals, err = readJSONDstFile(dir, cfg.Dst)
+	} else {
+		dstFile, err = readDstFile(dir, cfg.Dst, p)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error reading destination file: %w", err)
 	}
 
+	if err := s.prepare(cfg, dir, p, srcDoc, dstFile); err != nil {
+		return nil, err
+	}
+
+	if cfg.ReportFile != "" {
+		s.reportFile = resolvePath(dir, cfg.ReportFile)
+	}
+	if cfg.RedactOut != "" {
+		s.redactOut = resolvePath(dir, cfg.RedactOut)
+		if s.redactOut == dstFile.Path {
+			return nil, fmt.Errorf("--redact-out must not be the destination itself")
+		}
+	}
+	if cfg.Patch != "" && cfg.Patch != "-" {
+		s.patchPath = resolvePath(dir, cfg.Patch)
+	}
+	if cfg.Manifest != "" {
+		s.manifestPath = resolvePath(dir, cfg.Manifest)
+	}
+	if cfg.AuditLog != "" {
+		s.auditLog = resolvePath(dir, cfg.AuditLog)
+	}
+	if cfg.Out != "" && resolvePath(dir, cfg.Out) != resolvePath(dir, cfg.Dst) {
+		s.outPath = resolvePath(dir, cfg.Out)
+	}
+	if cfg.ConflictsOut != "" {
+		s.conflictsOut = resolvePath(dir, cfg.ConflictsOut)
+	}
+	if cfg.BackupDir != "" {
+		s.backupDir = resolvePath(dir, cfg.BackupDir)
+	}
+	if cacheable(cfg) {
+		s.cacheConfig = &cfg
+	}
+
+	if s.threeWay {
+		lock, err := s.readLock()
+		if err != nil {
+			return nil, err
+		}
+		s.ancestor = lock
+	}
+	s.parseDuration = time.Since(parseStart)
+
+	return s, nil
+}
+
+// prepare finishes s for a run over srcDoc and dstFile, parsed with p, in the
+// same way for files and pipe requests. dir resolves the destination
+// template.
+func (s *Service) prepare(cfg config.Config, dir string, p parser, srcDoc *field.Document, dstFile *field.File) error {
+	var err error
+	if cfg.Interpolate && merges(cfg) {
+		if err := interpolate(srcDoc, os.LookupEnv, cfg.InterpolateStrict); err != nil {
+			return err
+		}
+	}
+
+	if cfg.UpperKeys {
+		upperKeys(srcDoc)
+		upperKeys(dstFile.Doc)
+		dstFile.Data = dstFile.Doc.Map()
+	}
+
+	s.lineEnding, err = resolveLineEnding(cfg.LineEnding, dstFile.Doc)
+	if err != nil {
+		return err
+	}
+
+	if cfg.ManagedRegion {
+		dstFile.Data, err = regionData(dstFile.Doc)
+		if err != nil {
+			return fmt.Errorf("error reading destination file: %w", err)
+		}
+	} else if s.dstFormat != dstFormatJSON && merges(cfg) {
+		dstFile.Data = resolveDuplicates(dstFile.Path, dstFile.Doc, dstFile.Data, cfg.DstDuplicates)
+	}
+
+	if cfg.DstTemplate != "" && merges(cfg) {
+		s.template, err = readTemplate(dir, cfg.DstTemplate, p)
+		if err != nil {
+			return fmt.Errorf("error reading destination template: %w", err)
+		}
+	}
+
+	s.dst = dstFile
+	s.ignored = ignoredKeys(dstFile.Doc)
+	s.src = srcDoc.Map()
+	s.mapValues()
+	s.srcEntries = srcDoc.Vars()
+	s.orderHint = orderHint(srcDoc, s.src)
+	s.parseWarnings = *p.warnings
+	logParseWarnings(s.parseWarnings)
+	if merges(cfg) {
+		s.warnMissingOnlyKeys()
+	}
+
+	return nil
+}
+
+// merges reports whether cfg asks for a merge, as opposed to a mode that only
+// reads or rewrites the destination.
+func merges(cfg config.Config) bool {
+	return !cfg.Fmt && !cfg.Compact && !cfg.Dedupe && cfg.Since == "" && cfg.RedactOut == ""
+}
+
+func parserFor(cfg config.Config) parser {
+	return parser{
+		maxLineSize:      cfg.MaxLineSize,
+		noTrim:           cfg.NoTrim,
+		decodeEscapes:    cfg.DecodeEscapes,
+		typeHints:        cfg.ValidateTypes,
+		meta:             cfg.Meta,
+		recover:          cfg.Recover,
+		validateKeys:     cfg.ValidateKeys,
+		allowNumericKeys: cfg.AllowNumericKeys,
+		keyPolicy:        cfg.KeyPolicy,
+		stripControl:     cfg.ControlChars == controlCharsStrip,
+		lenient:          cfg.Lenient || cfg.FailOnParseWarning,
+		warnings:         new([]parseWarning),
+	}
+}
+
+// errAddOnlyForce rejects a run that asks for --add-only and updates at once.
+var errAddOnlyForce = errors.New("--add-only cannot be combined with --force or --force-keys")
+
+// errThreeWayForce rejects a --three-way run that also says which keys to
+// update; the merge decides that itself.
+var errThreeWayForce = errors.New("--three-way cannot be combined with --force, --force-keys or --add-only")
+
+// configure builds a Service from the options in cfg, without reading the
+// source or the destination.
+func configure(cfg config.Config, dir string) (*Service, error) {
+	if cfg.AddOnly && (cfg.Force || cfg.ForceKeys != "") {
+		return nil, errAddOnlyForce
+	}
+	if cfg.ThreeWay && (cfg.Force || cfg.ForceKeys != "" || cfg.AddOnly) {
+		return nil, errThreeWayForce
+	}
+
+	var (
+		forceKeys map[string]struct{}
+		err       error
+	)
+	if cfg.ForceKeys != "" {
+		forceKeys, err = readKeysFile(dir, cfg.ForceKeys)
+		if err != nil {
+			return nil, fmt.Errorf("error reading force keys file: %w", err)
+		}
+	}
+	if cfg.UpperKeys && forceKeys != nil {
+		upper := make(map[string]struct{}, len(forceKeys))
+		for k := range forceKeys {
+			upper[strings.ToUpper(k)] = struct{}{}
+		}
+		forceKeys = upper
+	}
+
+	var onlyKeys map[string]struct{}
+	if cfg.KeysFile != "" {
+		onlyKeys, err = readKeysFile(dir, cfg.KeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading keys file: %w", err)
+		}
+		if cfg.UpperKeys {
+			upper := make(map[string]struct{}, len(onlyKeys))
+			for k := range onlyKeys {
+				upper[strings.ToUpper(k)] = struct{}{}
+			}
+			onlyKeys = upper
+		}
+	}
+
+	var placeholder *regexp.Regexp
+	if cfg.PlaceholderPattern != "" {
+		placeholder, err = regexp.Compile(cfg.PlaceholderPattern)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing placeholder pattern: %w", err)
+		}
+	}
+
+	renameSpecs := cfg.Renames
+	if cfg.RenameFile != "" {
+		fromFile, err := readRenamesFile(dir, cfg.RenameFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading rename file: %w", err)
+		}
+		renameSpecs = append(fromFile, renameSpecs...)
+	}
+	renames, err := parseRenames(renameSpecs)
+	if err != nil {
+		return nil, err
+	}
+
+	var valueMap *valueMap
+	if cfg.MapFile != "" {
+		valueMap, err = readValueMapFile(dir, cfg.MapFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading map file: %w", err)
+		}
+		if cfg.UpperKeys {
+			valueMap.upperKeys()
+		}
+	}
+
+	exclude, err := parseKeyFilter(cfg.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing exclude patterns: %w", err)
+	}
+
+	// Keys are upper-cased before matching, so the patterns are too.
+	secrets, err := parseKeyFilter(strings.ToUpper(cfg.SecretPattern))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing secret patterns: %w", err)
+	}
+
+	switch cfg.DstFormat {
+	case "", dstFormatEnv:
+	case dstFormatJSON:
+		if cfg.Fmt || cfg.Compact || cfg.Dedupe || cfg.ManagedRegion {
+			return nil, fmt.Errorf("--dst-format json cannot be combined with --fmt, --compact, --dedupe or --managed-region")
+		}
+	default:
+		return nil, fmt.Errorf("unknown destination format %q (want env or json)", cfg.DstFormat)
+	}
+	if cfg.KeyPolicy != "" && cfg.KeyPolicy != keyPolicyStrict && cfg.KeyPolicy != keyPolicyFirstToken {
+		return nil, fmt.Errorf("unknown key policy %q (want strict or first-token)", cfg.KeyPolicy)
+	}
+	if cfg.InteractiveFallback != "" && cfg.InteractiveFallback != interactiveFallbackNone && cfg.InteractiveFallback != interactiveFallbackAll {
+		return nil, fmt.Errorf("unknown interactive fallback %q (want none or all)", cfg.InteractiveFallback)
+	}
+	if cfg.ControlChars != "" && cfg.ControlChars != controlCharsReject && cfg.ControlChars != controlCharsStrip {
+		return nil, fmt.Errorf("unknown control character handling %q (want reject or strip)", cfg.ControlChars)
+	}
+	if cfg.DstDuplicates != "" && cfg.DstDuplicates != dstDuplicatesLast && cfg.DstDuplicates != dstDuplicatesFirst && cfg.DstDuplicates != dstDuplicatesWarn {
+		return nil, fmt.Errorf("unknown destination duplicates policy %q (want last, first or warn)", cfg.DstDuplicates)
+	}
+
+	var owner *fileOwner
+	if cfg.Owner != "" {
+		owner, err = parseOwner(cfg.Owner)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	outPerm := defaultOutPerm
+	if cfg.OutPerm != "" {
+		outPerm, err = parsePerm(cfg.OutPerm)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var since time.Time
+	if cfg.Since != "" {
+		since, err = parseSince(cfg.Since)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &Service{
-		force: force,
-		dst:   dstFile,
-		src:   srcContent,
+		force:                 cfg.Force,
+		forceKeys:             forceKeys,
+		placeholder:           placeholder,
+		exclude:               exclude,
+		onlyKeys:              onlyKeys,
+		summary:               cfg.Summary,
+		warnSimilar:           cfg.WarnSimilar,
+		failOnDestOnly:        cfg.FailOnDestOnly,
+		srcName:               cfg.Src,
+		dstName:               cfg.Dst,
+		reportAppend:          cfg.ReportAppend,
+		shellArrays:           cfg.ShellArrays,
+		dryRun:                cfg.DryRun,
+		format:                cfg.Format,
+		secrets:               secrets,
+		minSecretLength:       cfg.MinSecretLength,
+		valueCaseInsensitive:  cfg.ValueCaseInsensitive,
+		auditActor:            cfg.AuditActor,
+		validateTypes:         cfg.ValidateTypes,
+		effective:             cfg.PrintEffective,
+		maskSecrets:           cfg.MaskSecrets,
+		managedRegion:         cfg.ManagedRegion,
+		since:                 since,
+		respectManaged:        cfg.RespectManaged,
+		lock:                  cfg.Lock || cfg.ThreeWay,
+		threeWay:              cfg.ThreeWay,
+		ensureGitignore:       cfg.EnsureGitignore,
+		patchPath:             cfg.Patch,
+		groupByPrefix:         cfg.GroupByPrefix,
+		dstFormat:             cfg.DstFormat,
+		failOnParseWarning:    cfg.FailOnParseWarning,
+		previewLines:          cfg.PreviewLines,
+		targetExistingOnly:    cfg.TargetExistingOnly,
+		init:                  cfg.Init,
+		normalizeBooleans:     cfg.NormalizeBools,
+		githubOutput:          os.Getenv(githubOutputEnv),
+		addOnly:               cfg.AddOnly,
+		failOnDeprecated:      cfg.FailOnDeprecated,
+		checksum:              cfg.Checksum,
+		interactive:           cfg.Interactive,
+		trace:                 cfg.Trace,
+		blankValues:           cfg.BlankValues,
+		backup:                cfg.Backup || cfg.BackupDir != "",
+		interactiveFallback:   cfg.InteractiveFallback,
+		stdin:                 os.Stdin,
+		stdinIsTerminal:       cfg.Interactive && isTerminal(os.Stdin),
+		owner:                 owner,
+		outPerm:               outPerm,
+		maxChanges:            cfg.MaxChanges,
+		confirmLarge:          cfg.ConfirmLarge,
+		collapseWhitespace:    cfg.CollapseWhitespace,
+		stdout:                os.Stdout,
+		carryComments:         cfg.CarryComments,
+		mergeCommentsFromDest: cfg.MergeCommentsFromDest,
+		renames:               renames,
+		valueMap:              valueMap,
+		removeRenamed:         cfg.RemoveRenamed,
+		decodeEscapes:         cfg.DecodeEscapes,
+		stripExport:           cfg.StripExport,
 	}, nil
 }
 
@@ -50,76 +583,701 @@ func (s *Service) Run() error {
 			_ = s.dst.Dsc.Close()
 		}
 	}()
+	s.dstRewritten = false
 
-	if s.force {
-		updates := s.determineUpdates()
-		if len(updates) > 0 {
-			if err := s.writeVars(updates, true); err != nil {
-				return fmt.Errorf("error writing vars (force): %w", err)
+	if s.failOnDestOnly {
+		if keys := s.destOnlyKeys(); len(keys) > 0 {
+			return fmt.Errorf("%w: %s", field.ErrDestOnlyKeys, strings.Join(keys, ", "))
+		}
+	}
+	if err := s.checkDeprecated(); err != nil {
+		return err
+	}
+
+	plan := s.Plan()
+	if s.trace {
+		s.tracePlan()
+	}
+	var conflicts []string
+	if s.threeWay {
+		conflicts = s.mergeConflicts()
+		logConflicts(conflicts)
+	}
+	if s.validateTypes {
+		if err := s.checkTypes(plan); err != nil {
+			return err
+		}
+	}
+	if err := s.checkParseWarnings(); err != nil {
+		// --dry-run still shows what the run would have done.
+		if s.dryRun {
+			if err := s.printPlan(plan.Vars); err != nil {
+				return err
 			}
 		}
+		return err
+	}
+	if s.effective {
+		return s.printEffective(plan)
+	}
+	if s.checksum {
+		return s.printChecksum(plan)
+	}
+	if s.blankValues {
+		return s.printBlankKeys()
+	}
+	if s.format == formatSh {
+		return s.printShell(plan)
+	}
+	if s.patchPath != "" {
+		return s.writePatch(plan)
+	}
+	if s.dryRun {
+		return s.printPlan(plan.Vars)
+	}
+	if s.interactive {
+		var err error
+		if plan, err = s.confirmPlan(plan); err != nil {
+			return err
+		}
+	}
+
+	if err := s.checkChanges(plan); err != nil {
+		return err
+	}
+	if s.targetExistingOnly && !s.init && len(s.dst.Data) == 0 && len(plan.Vars) > 0 {
+		return fmt.Errorf("%w: %s; pass --init to populate it", field.ErrEmptyDestination, s.dst.Path)
+	}
+
+	if s.lock {
+		if err := s.checkLock(plan); err != nil {
+			return err
+		}
+	}
+	if s.threeWay {
+		if err := s.writeConflicts(conflicts); err != nil {
+			return err
+		}
+	}
+
+	if err := s.checkUnmodified(); err != nil {
+		return err
+	}
+
+	writeStart := time.Now()
+	dropped := !s.managedRegion && s.removeRenamed && s.dropRenamed(plan.Vars)
+	stripped := !s.managedRegion && s.stripExport && stripExports(s.dst.Doc)
+	if s.dstFormat == dstFormatJSON {
+		if len(plan.Vars) > 0 {
+			content, err := s.renderJSON(plan)
+			if err != nil {
+				return err
+			}
+			if err := s.rewrite(content); err != nil {
+				return err
+			}
+		}
+	} else if s.managedRegion {
+		if len(plan.Vars) > 0 {
+			content, err := s.renderManaged(plan)
+			if err != nil {
+				return err
+			}
+			if err := s.rewrite(content); err != nil {
+				return err
+			}
+		}
+	} else if s.template != nil && len(s.dst.Doc.Entries) == 0 && len(plan.Vars) > 0 {
+		content, err := s.renderTemplate(plan)
+		if err != nil {
+			return err
+		}
+		if err := s.rewrite(content); err != nil {
+			return err
+		}
+	} else if marker := insertMarkerIndex(s.dst.Doc); (marker >= 0 && len(plan.Vars) > 0) || dropped || stripped {
+		content, err := s.render(plan)
+		if err != nil {
+			return err
+		}
+		if err := s.rewrite(content); err != nil {
+			return err
+		}
 	} else {
-		newVars := s.determineNewVars()
-		if len(newVars) > 0 {
-			if err := s.writeVars(newVars, false); err != nil {
-				return fmt.Errorf("error writing new vars: %w", err)
+		if s.outPath != "" {
+			if err := s.openOut(s.dst.Doc.String()); err != nil {
+				return err
 			}
+			defer func() {
+				_ = s.out.Close()
+				s.out = nil
+			}()
+		}
+
+		if err := s.writeVars(plan.Vars, plan.Force); err != nil {
+			return err
+		}
+	}
+
+	if s.reportFile != "" {
+		if err := s.writeReport(plan); err != nil {
+			return fmt.Errorf("error writing report: %w", err)
+		}
+	}
+	if s.lock {
+		if err := s.writeLock(plan); err != nil {
+			return fmt.Errorf("error writing lock file: %w", err)
+		}
+	}
+
+	if s.auditLog != "" {
+		if err := s.writeAudit(plan); err != nil {
+			return fmt.Errorf("error writing audit log: %w", err)
+		}
+	}
+
+	if s.manifestPath != "" {
+		if err := s.writeManifest(); err != nil {
+			return fmt.Errorf("error writing manifest: %w", err)
+		}
+	}
+
+	if s.githubOutput != "" {
+		if err := s.writeGitHubOutput(plan); err != nil {
+			return fmt.Errorf("error writing GitHub Actions output: %w", err)
+		}
+	}
+
+	if s.ensureGitignore {
+		written := s.dst.Path
+		if s.outPath != "" {
+			written = s.outPath
+		}
+		if err := ensureGitignore(written); err != nil {
+			return fmt.Errorf("error updating .gitignore: %w", err)
+		}
+	}
+
+	if s.warnSimilar {
+		for _, group := range similarKeys(s.dst.Data, plan.Vars) {
+			slog.Default().Warn("keys differ only by case or surrounding underscores", "keys", group)
+		}
+	}
+
+	slog.Default().Info("dotenv synced",
+		"parse_duration", s.parseDuration,
+		"write_duration", time.Since(writeStart),
+		"bytes_written", s.bytesWritten,
+	)
+	if len(plan.Vars) == 0 && !dropped && !stripped && len(conflicts) == 0 {
+		s.writeCache()
+	}
+	return conflictsError(conflicts)
+}
+
+// Plan is what a run appends to the destination: Vars are the keys to write,
+// Force marks the block as a force sync.
+type Plan struct {
+	Vars  map[string]string
+	Force bool
+}
+
+// Plan computes the vars to append without touching any file. Callers may
+// inspect or modify the result before handing it to Apply.
+func (s *Service) Plan() Plan {
+	plan := Plan{Vars: s.determineNewVars()}
+	switch {
+	case s.threeWay:
+		plan = Plan{Vars: s.determineMerge(), Force: true}
+	case !s.addOnly && (s.force || len(s.forceKeys) > 0):
+		plan = Plan{Vars: s.determineUpdates(), Force: true}
+	}
+	s.carryRenamed(plan.Vars)
+	if s.normalizeBooleans {
+		s.normalizeBools(plan.Vars)
+	}
+
+	return plan
+}
+
+// Apply writes the plan as a sync block to w. An empty plan writes nothing.
+func (s *Service) Apply(plan Plan, w io.Writer) error {
+	if len(plan.Vars) == 0 {
+		return nil
+	}
+
+	if err := s.writeBlock(w, plan.Vars, plan.Force); err != nil {
+		if plan.Force {
+			return fmt.Errorf("error writing vars (force): %w", err)
+		}
+		return fmt.Errorf("error writing new vars: %w", err)
+	}
+
+	return nil
+}
+
+// render returns the whole destination with plan applied: at the insert
+// marker when there is one, otherwise appended.
+func (s *Service) render(plan Plan) (string, error) {
+	if marker := insertMarkerIndex(s.dst.Doc); marker >= 0 && len(plan.Vars) > 0 {
+		return s.renderAtMarker(plan, marker)
+	}
+
+	var b strings.Builder
+	b.WriteString(s.dst.Doc.String())
+	if err := s.Apply(plan, &b); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// openOut creates the separate output file and seeds it with content, usually
+// the destination's current text so an appended block completes a full file.
+func (s *Service) openOut(content string) error {
+	slog.Default().Info("Writing file", "path", s.outPath)
+
+	created := fileCreated(s.outPath)
+	out, err := os.OpenFile(s.outPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, s.outFileMode())
+	if err != nil {
+		return fmt.Errorf("open %q: %w", s.outPath, err)
+	}
+	if created {
+		s.chmodCreated(s.outPath)
+		s.chownCreated(s.outPath)
+	}
+
+	n, err := out.WriteString(content)
+	s.bytesWritten += n
+	if err != nil {
+		_ = out.Close()
+		return fmt.Errorf("error writing base content to %q: %w", s.outPath, err)
+	}
+
+	s.out = out
+	return nil
+}
+
+// rewrite replaces the whole output with content: the separate output file
+// when one is set, otherwise the destination in place.
+func (s *Service) rewrite(content string) error {
+	if err := s.checkUnmodified(); err != nil {
+		return err
+	}
+	if s.outPath != "" {
+		if err := s.openOut(content); err != nil {
+			return err
+		}
+
+		err := s.out.Close()
+		s.out = nil
+		return err
+	}
+
+	if err := s.openDst(); err != nil {
+		return err
+	}
+
+	if old, err := os.ReadFile(s.dst.Path); err != nil || string(old) != content {
+		s.dstRewritten = true
+	}
+	if err := s.dst.Dsc.Truncate(0); err != nil {
+		return fmt.Errorf("error truncating destination: %w", err)
+	}
+
+	n, err := s.dst.Dsc.WriteString(content)
+	s.bytesWritten += n
+	if err != nil {
+		return fmt.Errorf("error rewriting destination: %w", err)
+	}
+
+	return nil
+}
+
+// openDst opens the destination for appending, creating it if needed, unless
+// it is open already. Nothing seeks on the handle: with O_APPEND each write
+// goes to the current end of the file, which is all appending and rewrite
+// (truncate, then write) need.
+func (s *Service) openDst() error {
+	if s.dst.Dsc != nil {
+		return nil
+	}
+	if err := s.backupDst(); err != nil {
+		return err
+	}
+
+	slog.Default().Info("Writing file", "path", s.dst.Path)
+
+	created := fileCreated(s.dst.Path)
+	f, err := os.OpenFile(s.dst.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open %q: %w", s.dst.Path, err)
+	}
+	if created {
+		s.chownCreated(s.dst.Path)
+	}
+
+	s.dst.Dsc = f
+	return nil
+}
+
+// target is where merged vars are written: the output file when one is set,
+// otherwise the destination itself.
+func (s *Service) target() io.Writer {
+	if s.out != nil {
+		return countingWriter{w: s.out, n: &s.bytesWritten}
+	}
+
+	return countingWriter{w: s.dst.Dsc, n: &s.bytesWritten}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n *int
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += n
+
+	return n, err
+}
+
+func (s *Service) determineNewVars() map[string]string {
+	newVars := make(map[string]string, len(s.src))
+	for variable, val := range s.src {
+		if !s.selected(variable) || s.isIgnored(variable) {
+			continue
+		}
+		if _, ok := s.dst.Data[variable]; !ok {
+			newVars[variable] = val
+		}
+	}
+
+	return newVars
+}
+
+// determineUpdates returns missing keys plus keys whose values differ, the
+// latter only for keys that are forced (every key in --force mode, or the
+// ones listed in the --force-keys file) and, with --respect-managed, marked
+// managed in the destination. A placeholder source value never overwrites an
+// existing destination value.
+func (s *Service) determineUpdates() map[string]string {
+	updates := make(map[string]string, len(s.src))
+	for k, v := range s.src {
+		if !s.selected(k) || s.isIgnored(k) {
+			continue
+		}
+		old, ok := s.dst.Data[k]
+		if !ok || (!s.valuesEqual(old, v) && s.isForced(k) && s.isManaged(k) && !s.isPlaceholder(v)) {
+			updates[k] = v
+		}
+	}
+
+	return updates
+}
+
+// checkChanges enforces maxChanges on force plans, counting both added and
+// updated keys.
+func (s *Service) checkChanges(plan Plan) error {
+	if !plan.Force || s.maxChanges <= 0 || len(plan.Vars) <= s.maxChanges {
+		return nil
+	}
+
+	updated := 0
+	for k := range plan.Vars {
+		if _, ok := s.dst.Data[k]; ok {
+			updated++
+		}
+	}
+
+	if s.confirmLarge {
+		slog.Default().Warn("large force run confirmed",
+			"changes", len(plan.Vars), "updated", updated, "max_changes", s.maxChanges)
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d changes (%d added, %d updated), limit is %d; pass --confirm-large to proceed",
+		field.ErrTooManyChanges, len(plan.Vars), len(plan.Vars)-updated, updated, s.maxChanges)
+}
+
+// selected reports whether key is in scope: not excluded and, with a keys
+// file, listed in it.
+func (s *Service) selected(key string) bool {
+	if !s.exclude.included(key) {
+		return false
+	}
+	if s.onlyKeys == nil {
+		return true
+	}
+
+	_, ok := s.onlyKeys[key]
+	return ok
+}
+
+// warnMissingOnlyKeys warns about keys-file entries the source does not
+// define; they are otherwise ignored.
+func (s *Service) warnMissingOnlyKeys() {
+	var missing []string
+	for k := range s.onlyKeys {
+		if _, ok := s.src[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	sort.Strings(missing)
+	slog.Default().Warn("keys file lists keys missing from source", "keys", missing)
+}
+
+// destOnlyKeys returns the sorted keys present in the destination but not in
+// the source, ignoring old names of renamed keys.
+func (s *Service) destOnlyKeys() []string {
+	var keys []string
+	for k := range s.dst.Data {
+		if _, ok := s.src[k]; !ok && !s.isRenamedFrom(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// valuesEqual compares a destination and a source value, ignoring case when
+// valueCaseInsensitive is set and boolean spelling with normalizeBooleans.
+func (s *Service) valuesEqual(a, b string) bool {
+	if s.normalizeBooleans {
+		a, b = normalizeBool(a), normalizeBool(b)
+	}
+	if s.valueCaseInsensitive {
+		return strings.EqualFold(a, b)
+	}
+
+	return a == b
+}
+
+// managedAnnotation, on the line above a destination key, allows force
+// updates of that key under --respect-managed.
+const managedAnnotation = "# envmerge:managed"
+
+// isManaged reports whether force may update key: always, unless
+// respectManaged limits it to annotated destination keys.
+func (s *Service) isManaged(key string) bool {
+	if !s.respectManaged {
+		return true
+	}
+
+	for _, e := range s.dst.Doc.Entries {
+		if e.Kind == field.KindVar && e.Key == key && slices.Contains(e.Doc, managedAnnotation) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Service) isPlaceholder(value string) bool {
+	return s.placeholder != nil && s.placeholder.MatchString(value)
+}
+
+func (s *Service) isForced(key string) bool {
+	if s.force {
+		return true
+	}
+
+	_, ok := s.forceKeys[key]
+	return ok
+}
+
+// writeVars appends vars as a sync block to the output file, or to the
+// destination, opened only once there is something to append.
+func (s *Service) writeVars(vars map[string]string, isForce bool) error {
+	if len(vars) == 0 {
+		return nil
+	}
+	if s.out == nil {
+		if err := s.openDst(); err != nil {
+			return err
+		}
+	}
+
+	return s.Apply(Plan{Vars: vars, Force: isForce}, s.target())
+}
+
+// writeBlock writes vars under a sync header; with no vars it writes nothing,
+// so callers never leave an orphan header behind. The block goes out in a
+// single Write: under O_APPEND every write lands at the end of the file
+// whatever the offset, so one write keeps the block contiguous even if
+// another writer appends in between.
+func (s *Service) writeBlock(w io.Writer, vars map[string]string, isForce bool) error {
+	if len(vars) == 0 {
+		return nil
+	}
+
+	keys, hinted := s.blockKeys(vars)
+
+	header := "\n# envmerge sync run: %s\n"
+	if isForce {
+		header = "\n# envmerge sync run (force): %s\n"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, header, time.Now().Format(time.DateTime))
+	if s.summary {
+		b.WriteString(s.summaryLine(vars))
+	}
+
+	for i, k := range keys {
+		v := vars[k]
+
+		if s.groupByPrefix && i > 0 && (i == hinted || i > hinted && s.groupOf(k) != s.groupOf(keys[i-1])) {
+			b.WriteString("\n")
+		}
+
+		for _, c := range s.carriedComments(k) {
+			b.WriteString(c + "\n")
+		}
+
+		if s.collapseWhitespace {
+			v = s.collapseValue(k, v)
+		}
+
+		fmt.Fprintf(&b, "%s%s=%s\n", s.keyPrefix(k), k, s.formatValue(v))
+	}
+
+	if err := s.write(w, b.String()); err != nil {
+		return fmt.Errorf("error writing sync block: %w", err)
+	}
+
+	return nil
+}
+
+// blockKeys returns the keys of vars in write order: those named by the
+// source's order hint first, in hint order, then the rest sorted, grouped by
+// group with groupByPrefix. hinted is the number of hinted keys.
+func (s *Service) blockKeys(vars map[string]string) (keys []string, hinted int) {
+	keys = make([]string, 0, len(vars))
+	for _, k := range s.orderHint {
+		if _, ok := vars[k]; ok && !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	hinted = len(keys)
+
+	rest := make([]string, 0, len(vars)-hinted)
+	for k := range vars {
+		if !slices.Contains(keys[:hinted], k) {
+			rest = append(rest, k)
 		}
 	}
+	sort.Strings(rest)
+	if s.groupByPrefix {
+		// Plain order can split a group: DBX sorts between DB and DB_HOST.
+		sort.SliceStable(rest, func(i, j int) bool {
+			return s.groupOf(rest[i]) < s.groupOf(rest[j])
+		})
+	}
 
-	slog.Default().Info("dotenv synced")
-	return nil
+	return append(keys, rest...), hinted
 }
 
-func (s *Service) determineNewVars() map[string]string {
-	newVars := make(map[string]string, len(s.src))
-	for variable, val := range s.src {
-		if _, ok := s.dst.Data[variable]; !ok {
-			newVars[variable] = val
-		}
+// keyGroup returns the part of key before its first underscore, or the whole
+// key when it has none.
+func keyGroup(key string) string {
+	group, _, _ := strings.Cut(key, "_")
+	return group
+}
+
+// carriedComments returns the source comments to write above key. Keys already
+// in the destination keep their local comments unless mergeCommentsFromDest
+// is off.
+func (s *Service) carriedComments(key string) []string {
+	if !s.carryComments {
+		return nil
+	}
+	if _, ok := s.dst.Data[key]; ok && s.mergeCommentsFromDest {
+		return nil
 	}
 
-	return newVars
+	return s.srcEntries[key].Doc
 }
 
-func (s *Service) determineUpdates() map[string]string {
-	updates := make(map[string]string, len(s.src))
-	for k, v := range s.src {
-		old, ok := s.dst.Data[k]
-		if !ok || old != v {
-			updates[k] = v
-		}
+func (s *Service) eol() string {
+	if s.lineEnding == "" {
+		return "\n"
 	}
 
-	return updates
+	return s.lineEnding
 }
 
-func (s *Service) writeVars(vars map[string]string, isForce bool) error {
-	keys := make([]string, 0, len(vars))
+// write sends text to w using the configured line ending; multiline values
+// are converted too, so interior lines match the closing one.
+func (s *Service) write(w io.Writer, text string) error {
+	if s.lineEnding != "" && s.lineEnding != "\n" {
+		text = strings.ReplaceAll(text, "\n", s.lineEnding)
+	}
+
+	_, err := io.WriteString(w, text)
+	return err
+}
+
+// summaryLine describes a block as "# envmerge: +N keys, ~M updated from SRC".
+// It deliberately does not share the "# envmerge sync run" prefix, so it is
+// never mistaken for a block header.
+func (s *Service) summaryLine(vars map[string]string) string {
+	added, updated := 0, 0
 	for k := range vars {
-		keys = append(keys, k)
+		if _, ok := s.dst.Data[k]; ok {
+			updated++
+		} else {
+			added++
+		}
 	}
-	sort.Strings(keys)
 
-	header := "\n# envmerge sync run: %s\n"
-	if isForce {
-		header = "\n# envmerge sync run (force): %s\n"
+	return fmt.Sprintf("# envmerge: +%d keys, ~%d updated from %s\n", added, updated, s.srcName)
+}
+
+// shellArrayPattern matches bash array literals such as (a b c) that would
+// break if quoted.
+var shellArrayPattern = regexp.MustCompile(`^\([^"#\r\n]*\)$`)
+
+// whitespaceRun matches the runs collapseValue replaces with one space.
+var whitespaceRun = regexp.MustCompile(`[ \t]{2,}|\t`)
+
+// collapseValue collapses internal runs of spaces and tabs in v to a single
+// space. Values quoted in the source and multiline values are left alone.
+func (s *Service) collapseValue(key, v string) string {
+	if s.srcEntries[key].Quoted || strings.ContainsAny(v, "\r\n") {
+		return v
 	}
 
-	if _, err := s.dst.Dsc.WriteString(fmt.Sprintf(header, time.Now().Format(time.DateTime))); err != nil {
-		return fmt.Errorf("error writing header: %w", err)
+	core := strings.Trim(v, " \t")
+	if core == "" {
+		return v
 	}
 
-	for _, k := range keys {
-		v := vars[k]
+	start := strings.Index(v, core)
+	return v[:start] + whitespaceRun.ReplaceAllString(core, " ") + v[start+len(core):]
+}
+
+// formatValue applies the write-path options on top of formatEnvValue.
+func (s *Service) formatValue(v string) string {
+	if s.shellArrays && shellArrayPattern.MatchString(v) {
+		return v
+	}
 
-		line := fmt.Sprintf("%s=%s\n", k, formatEnvValue(v))
-		if _, err := s.dst.Dsc.WriteString(line); err != nil {
-			return fmt.Errorf("error writing var %q: %w", k, err)
+	// Unquoted values are decoded on read, so write them encoded; a value
+	// that needs quotes anyway is written quoted, where escapes are literal.
+	if s.decodeEscapes {
+		if encoded := encodeEscapes(v); encoded != v && formatEnvValue(encoded) == encoded {
+			return encoded
 		}
 	}
 
-	return nil
+	return formatEnvValue(v)
 }
 
 func formatEnvValue(v string) string {
@@ -138,13 +1296,21 @@ func formatEnvValue(v string) string {
 		return v
 	}
 
+	return quoteEnvValue(v)
+}
+
+func quoteEnvValue(v string) string {
 	escaped := strings.ReplaceAll(v, `\`, `\\`)
 	escaped = strings.ReplaceAll(escaped, `"`, `\"`)
 
 	return `"` + escaped + `"`
 }
 
-func readSrcFile(dir, file string) (map[string]string, error) {
+func readSrcFile(dir, file string, p parser) (*field.Document, error) {
+	if strings.HasPrefix(file, gitSourcePrefix) {
+		return readGitSrcFile(dir, file, p)
+	}
+
 	filePath := resolvePath(dir, file)
 	slog.Default().Info("Reading file", "path", filePath)
 
@@ -157,45 +1323,215 @@ func readSrcFile(dir, file string) (map[string]string, error) {
 	}
 	defer content.Close()
 
-	data, err := fileContent(content)
+	doc, err := p.sourceDocument(filePath, content)
 	if err != nil {
 		return nil, fmt.Errorf("error reading file %q: %w", filePath, err)
 	}
 
-	return data, nil
+	return doc, nil
 }
 
-func readDstFile(dir, file string) (*field.File, error) {
-	filePath := resolvePath(dir, file)
-	slog.Default().Info("Reading file", "path", filePath)
+// readSources reads the source layers and, when set, the overlay whose values
+// take precedence over them. A missing overlay is treated as empty. The result
+// holds the entries of every layer in precedence order, so its last-wins Map
+// is the effective source.
+func readSources(dir string, srcFS fs.FS, cfg config.Config, p parser) (*field.Document, error) {
+	read := func(name string) (*field.Document, error) {
+		var (
+			doc *field.Document
+			err error
+		)
+		if srcFS != nil {
+			doc, err = readSrcFS(srcFS, name, p)
+		} else {
+			doc, err = readSrcFile(dir, name, p)
+		}
+		if err != nil || !cfg.ExpandFileRefs {
+			return doc, err
+		}
+
+		if err := expandFileRefs(doc, dir, name, srcFS); err != nil {
+			return nil, fmt.Errorf("error expanding file references in %q: %w", name, err)
+		}
+		return doc, nil
+	}
+
+	detect := cfg.DetectConflicts || cfg.FailOnConflict
+	var layers []sourceLayer
+	readLayer := func(name string) (*field.Document, error) {
+		doc, err := read(name)
+		if err == nil && detect {
+			layers = append(layers, sourceLayer{name: name, env: doc.Map()})
+		}
+		return doc, err
+	}
 
-	content, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	src, err := readLayers(strings.Split(cfg.Src, ","), readLayer)
 	if err != nil {
-		return nil, fmt.Errorf("open %q: %w", filePath, err)
+		return nil, err
+	}
+	if detect {
+		if err := checkConflicts(layers, cfg.FailOnConflict); err != nil {
+			return nil, err
+		}
 	}
 
-	if _, err := content.Seek(0, io.SeekStart); err != nil {
-		_ = content.Close()
-		return nil, fmt.Errorf("seek start %q: %w", filePath, err)
+	if cfg.Overlay == "" {
+		return src, nil
 	}
 
-	data, err := fileContent(content)
+	overlay, err := read(cfg.Overlay)
+	if errors.Is(err, field.ErrFileDoesNotExist) {
+		slog.Default().Info("overlay not found, skipping", "path", cfg.Overlay)
+		return src, nil
+	}
 	if err != nil {
-		_ = content.Close()
-		return nil, fmt.Errorf("error reading file %q: %w", filePath, err)
+		return nil, fmt.Errorf("overlay: %w", err)
 	}
 
-	if _, err := content.Seek(0, io.SeekEnd); err != nil {
+	src.Entries = append(src.Entries, overlay.Entries...)
+	return src, nil
+}
+
+// upperKeys upper-cases every key in doc, so mixed-case keys compare equal to
+// their upper-case form and are written that way.
+func upperKeys(doc *field.Document) {
+	for i := range doc.Entries {
+		doc.Entries[i].Key = strings.ToUpper(doc.Entries[i].Key)
+	}
+}
+
+// readLayers reads a comma-separated --src list, lowest precedence first. A
+// single source must exist; in a longer list missing layers are skipped as
+// long as one of them is found.
+func readLayers(names []string, read func(string) (*field.Document, error)) (*field.Document, error) {
+	if len(names) == 1 {
+		return read(strings.TrimSpace(names[0]))
+	}
+
+	var src *field.Document
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		layer, err := read(name)
+		if errors.Is(err, field.ErrFileDoesNotExist) {
+			slog.Default().Warn("source layer not found, skipping", "path", name)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("source layer %q: %w", name, err)
+		}
+
+		if src == nil {
+			src = layer
+		} else {
+			src.Entries = append(src.Entries, layer.Entries...)
+		}
+	}
+
+	if src == nil {
+		return nil, field.ErrFileDoesNotExist
+	}
+
+	return src, nil
+}
+
+func readSrcFS(fsys fs.FS, name string, p parser) (*field.Document, error) {
+	slog.Default().Info("Reading file", "path", name, "fs", true)
+
+	content, err := fsys.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, field.ErrFileDoesNotExist
+		}
+		return nil, fmt.Errorf("open %q: %w", name, err)
+	}
+	defer content.Close()
+
+	doc, err := p.sourceDocument(name, content)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %q: %w", name, err)
+	}
+
+	return doc, nil
+}
+
+// readDstFile parses the destination through a read-only handle that is
+// closed right away; a missing file reads as empty. Writers open it later
+// with openDst.
+func readDstFile(dir, file string, p parser) (*field.File, error) {
+	filePath := resolvePath(dir, file)
+	slog.Default().Info("Reading file", "path", filePath)
+
+	doc := &field.Document{}
+
+	content, err := os.Open(filePath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// Created by openDst on the first write.
+	case err != nil:
+		return nil, fmt.Errorf("open %q: %w", filePath, err)
+	default:
+		p.file = filePath
+		doc, err = p.document(content)
 		_ = content.Close()
-		return nil, fmt.Errorf("seek end %q: %w", filePath, err)
+		if err != nil {
+			return nil, fmt.Errorf("error reading file %q: %w", filePath, err)
+		}
 	}
 
 	return &field.File{
-		Dsc:  content,
-		Data: data,
+		Path: filePath,
+		Data: doc.Map(),
+		Doc:  doc,
 	}, nil
 }
 
+func readKeysFile(dir, file string) (map[string]struct{}, error) {
+	filePath := resolvePath(dir, file)
+	slog.Default().Info("Reading file", "path", filePath)
+
+	content, err := os.Open(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, field.ErrFileDoesNotExist
+		}
+		return nil, fmt.Errorf("open %q: %w", filePath, err)
+	}
+	defer content.Close()
+
+	keys, err := keyList(content)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %q: %w", filePath, err)
+	}
+
+	return keys, nil
+}
+
+// keyList reads a newline-delimited list of keys, skipping blanks and comments.
+func keyList(r io.Reader) (map[string]struct{}, error) {
+	scanner := bufio.NewScanner(r)
+	keys := make(map[string]struct{})
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		keys[line] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning file: %w", err)
+	}
+
+	return keys, nil
+}
+
 func resolvePath(dir, file string) string {
 	if filepath.IsAbs(file) {
 		return file
@@ -204,72 +1540,455 @@ func resolvePath(dir, file string) string {
 	return filepath.Join(dir, file)
 }
 
+const defaultMaxLineSize = 1024 * 1024 // 1MB
+
+// parser holds the options that change how env files are read. The zero value
+// parses with the defaults.
+type parser struct {
+	maxLineSize int
+	// noTrim keeps leading/trailing whitespace of unquoted values.
+	noTrim bool
+	// decodeEscapes turns \n, \t and \\ in unquoted values into a newline,
+	// a tab and a backslash.
+	decodeEscapes bool
+	// typeHints strips a trailing "# type:NAME" comment from values and
+	// records NAME as the entry's type.
+	typeHints bool
+	// validateKeys rejects keys that are not POSIX identifiers;
+	// allowNumericKeys also accepts keys starting with a digit.
+	validateKeys     bool
+	allowNumericKeys bool
+	// keyPolicy decides what a key with whitespace in it, like "A B=1",
+	// means: an error (keyPolicyStrict) or its first token.
+	keyPolicy string
+	// stripControl removes control characters from values instead of
+	// rejecting them.
+	stripControl bool
+	// lenient skips malformed lines instead of failing, recording each in
+	// warnings; file names the input in them.
+	lenient  bool
+	warnings *[]parseWarning
+	file     string
+	// meta parses "# @meta {...}" comments into each entry's Meta.
+	meta bool
+	// recover keeps a multiline value left unterminated at the end of the
+	// input with what it had, instead of failing.
+	recover bool
+}
+
+// Values of --key-policy.
+const (
+	keyPolicyStrict     = "strict"
+	keyPolicyFirstToken = "first-token"
+)
+
+var (
+	posixKeyPattern   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	numericKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// validKey reports whether key is a POSIX identifier, or with
+// allowNumericKeys, one that may also start with a digit.
+func (p parser) validKey(key string) bool {
+	if p.allowNumericKeys {
+		return numericKeyPattern.MatchString(key)
+	}
+
+	return posixKeyPattern.MatchString(key)
+}
+
 func fileContent(r io.Reader) (map[string]string, error) {
-	scanner := bufio.NewScanner(r)
-	const maxToken = 1024 * 1024 // 1MB
-	scanner.Buffer(make([]byte, 1024), maxToken)
+	return parser{}.content(r)
+}
+
+func parseDocument(r io.Reader) (*field.Document, error) {
+	return parser{}.document(r)
+}
+
+func (p parser) content(r io.Reader) (map[string]string, error) {
+	doc, err := p.document(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.Map(), nil
+}
+
+func (p parser) document(r io.Reader) (*field.Document, error) {
+	maxLineSize := p.maxLineSize
+	if maxLineSize <= 0 {
+		maxLineSize = defaultMaxLineSize
+	}
+
+	// Reading everything up front lets each Raw be a slice of one string
+	// instead of a copy per line; the document keeps all of it anyway.
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading input: %w", err)
+	}
+	all := string(data)
 
-	env := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(all))
+	scanner.Buffer(make([]byte, min(1024, maxLineSize)), maxLineSize)
+	scanner.Split(scanRawLines)
+
+	doc := &field.Document{Entries: make([]field.Entry, 0, strings.Count(all, "\n")+1)}
 
 	var (
-		currentKey   string
+		current      field.Entry
 		currentValue strings.Builder
 		inMultiline  bool
+		lineNo       int
+		comment      []string
+		offset       int
 	)
 
 	for scanner.Scan() {
-		rawLine := scanner.Text()
+		rawLine := all[offset : offset+len(scanner.Bytes())]
+		offset += len(rawLine)
+		text := strings.TrimSuffix(strings.TrimSuffix(rawLine, "\n"), "\r")
+		lineNo++
 
 		if inMultiline {
-			line := strings.TrimSuffix(rawLine, "\r")
+			// The entry's lines are contiguous in all, so extend the
+			// slice instead of concatenating.
+			current.Raw = all[offset-len(current.Raw)-len(rawLine) : offset]
+
+			if end := closingQuoteIndex(text); end >= 0 {
+				// As on a single line, only an inline comment may follow
+				// the closing quote.
+				currentValue.WriteString("\n" + text[:end])
+				current.Value = unescapeQuoted(currentValue.String())
+				if p.typeHints {
+					_, current.Type = cutTypeHint(text[end+1:])
+				}
 
-			trimmedRight := strings.TrimRight(line, " \t")
-			if strings.HasSuffix(trimmedRight, `"`) && !strings.HasSuffix(trimmedRight, `\"`) {
-				trimmedRight = strings.TrimSuffix(trimmedRight, `"`)
-				currentValue.WriteString("\n" + trimmedRight)
-				env[currentKey] = currentValue.String()
+				err := checkAfterClosingQuote(text[end+1:])
+				if err != nil {
+					err = fmt.Errorf("invalid value for key %q on line %d: %w", current.Key, lineNo, err)
+				} else {
+					err = p.checkControlChars(&current)
+				}
+				if err != nil {
+					if err := p.tolerate(doc, current.Raw, current.Line, err); err != nil {
+						return nil, err
+					}
+				} else {
+					doc.Entries = append(doc.Entries, current)
+				}
 
 				inMultiline = false
-				currentKey = ""
+				current = field.Entry{}
 				currentValue.Reset()
 			} else {
-				currentValue.WriteString("\n" + line)
+				currentValue.WriteString("\n" + text)
 			}
 
 			continue
 		}
 
-		line := strings.TrimSpace(rawLine)
-		if line == "" || strings.HasPrefix(line, "#") {
+		line := strings.TrimSpace(text)
+		if line == "" {
+			doc.Entries = append(doc.Entries, field.Entry{Kind: field.KindBlank, Raw: rawLine, Line: lineNo})
+			comment = nil
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			doc.Entries = append(doc.Entries, field.Entry{Kind: field.KindComment, Raw: rawLine, Line: lineNo})
+			// A contiguous run of comments documents the key below it. A
+			// shebang belongs to the file, not to its first key, so it is
+			// never carried.
+			if lineNo != 1 || !strings.HasPrefix(line, "#!") {
+				comment = append(comment, line)
+			}
 			continue
 		}
 
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid env line: %q", line)
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			if err := p.tolerate(doc, rawLine, lineNo, fmt.Errorf("invalid env line: %q", line)); err != nil {
+				return nil, err
+			}
+			comment = nil
+			continue
+		}
+
+		key = strings.TrimSpace(key)
+		export := false
+		if k, ok := strings.CutPrefix(key, "export"); ok && k != "" && (k[0] == ' ' || k[0] == '\t') {
+			key, export = strings.TrimSpace(k), true
+		}
+		if i := strings.IndexAny(key, " \t"); i >= 0 {
+			if p.keyPolicy != keyPolicyFirstToken {
+				if err := p.tolerate(doc, rawLine, lineNo, fmt.Errorf("%w on line %d: %q contains whitespace", field.ErrInvalidKey, lineNo, key)); err != nil {
+					return nil, err
+				}
+				comment = nil
+				continue
+			}
+			key = key[:i]
+		}
+		if key == "" {
+			if err := p.tolerate(doc, rawLine, lineNo, fmt.Errorf("%w on line %d: %q", field.ErrEmptyKey, lineNo, line)); err != nil {
+				return nil, err
+			}
+			comment = nil
+			continue
+		}
+		if p.validateKeys && !p.validKey(key) {
+			if err := p.tolerate(doc, rawLine, lineNo, fmt.Errorf("%w on line %d: %q", field.ErrInvalidKey, lineNo, key)); err != nil {
+				return nil, err
+			}
+			comment = nil
+			continue
 		}
+		value = strings.TrimSpace(value)
+		if p.noTrim && !strings.HasPrefix(value, `"`) {
+			_, value, _ = strings.Cut(text, "=")
+		}
+		entry := field.Entry{Kind: field.KindVar, Key: key, Export: export, Raw: rawLine, Line: lineNo, Doc: comment}
+		comment = nil
+
+		if strings.HasPrefix(value, `"`) {
+			entry.Quoted = true
+
+			inner := value[1:]
+			end := closingQuoteIndex(inner)
+			if end < 0 {
+				// Trailing whitespace on the opening line is inside the
+				// quotes, so take it from the untrimmed text.
+				_, rest, _ := strings.Cut(text, "=")
+				rest = strings.TrimLeft(rest, " \t")
+
+				inMultiline = true
+				current = entry
+				currentValue.WriteString(strings.TrimPrefix(rest, `"`))
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+				continue
+			}
+
+			if err := checkAfterClosingQuote(inner[end+1:]); err != nil {
+				if err := p.tolerate(doc, rawLine, lineNo, fmt.Errorf("invalid value for key %q: %w", key, err)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			entry.Value = unescapeQuoted(inner[:end])
+			if p.typeHints {
+				_, entry.Type = cutTypeHint(inner[end+1:])
+			}
+			if err := p.checkControlChars(&entry); err != nil {
+				if err := p.tolerate(doc, rawLine, lineNo, err); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			doc.Entries = append(doc.Entries, entry)
 
-		if strings.HasPrefix(value, `"`) && !strings.HasSuffix(value, `"`) {
-			inMultiline = true
-			currentKey = key
-			currentValue.WriteString(strings.TrimPrefix(value, `"`))
+			continue
+		}
 
+		if p.typeHints {
+			value, entry.Type = cutTypeHint(value)
+		}
+		entry.Value = strings.Trim(value, `"`)
+		if p.decodeEscapes {
+			entry.Value = decodeEscapes(entry.Value)
+		}
+		if err := p.checkControlChars(&entry); err != nil {
+			if err := p.tolerate(doc, rawLine, lineNo, err); err != nil {
+				return nil, err
+			}
 			continue
 		}
+		doc.Entries = append(doc.Entries, entry)
+	}
 
-		env[key] = strings.Trim(value, `"`)
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			key := current.Key
+			if !inMultiline {
+				key = overlongLineKey(all[offset:])
+			}
+			if key == "" {
+				return nil, fmt.Errorf("%w: line %d exceeds %s limit",
+					field.ErrLineTooLong, lineNo+1, formatSize(maxLineSize))
+			}
+			return nil, fmt.Errorf("%w: line %d exceeds %s limit at key %q",
+				field.ErrLineTooLong, lineNo+1, formatSize(maxLineSize), key)
+		}
+		return nil, fmt.Errorf("error scanning file: %w", err)
 	}
 
 	if inMultiline {
-		return nil, fmt.Errorf("unterminated multiline value for key %q", currentKey)
+		if !p.recover {
+			return nil, fmt.Errorf("unterminated multiline value for key %q", current.Key)
+		}
+
+		// A file cut off mid-value, e.g. by a power loss, still shows
+		// what it had of it.
+		slog.Default().Warn("recovered unterminated multiline value", "path", p.file, "line", current.Line, "key", current.Key)
+		current.Value = unescapeQuoted(currentValue.String())
+		doc.Entries = append(doc.Entries, current)
+	}
+	if p.meta {
+		p.attachMeta(doc)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error scanning file: %w", err)
+	return doc, nil
+}
+
+// overlongLineKey returns the key on the first line of rest, the line the
+// scanner gave up on, or "" when it has no KEY= prefix.
+func overlongLineKey(rest string) string {
+	line, _, _ := strings.Cut(rest, "\n")
+	key, _, ok := strings.Cut(line, "=")
+	if !ok {
+		return ""
+	}
+	key = strings.TrimSpace(key)
+	if k, ok := strings.CutPrefix(key, "export"); ok && k != "" && (k[0] == ' ' || k[0] == '\t') {
+		key = strings.TrimSpace(k)
+	}
+	if strings.ContainsAny(key, " \t#") {
+		return ""
+	}
+
+	return key
+}
+
+// closingQuoteIndex returns the index of the first unescaped double quote in s,
+// or -1 if there is none.
+func closingQuoteIndex(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// checkAfterClosingQuote accepts nothing but an inline comment after a closing
+// quote; the comment must be separated from the quote by whitespace.
+func checkAfterClosingQuote(rest string) error {
+	trimmed := strings.TrimLeft(rest, " \t")
+	if trimmed == "" || (len(trimmed) < len(rest) && strings.HasPrefix(trimmed, "#")) {
+		return nil
+	}
+
+	return fmt.Errorf("unexpected text after closing quote: %q", rest)
+}
+
+// decodeEscapes decodes \n, \t and \\ of an unquoted value; any other
+// backslash is kept as is.
+func decodeEscapes(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch s[i+1] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case '\\':
+			b.WriteByte('\\')
+		default:
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+	}
+
+	return b.String()
+}
+
+// encodeEscapes is the inverse of decodeEscapes.
+func encodeEscapes(s string) string {
+	return escapeReplacer.Replace(s)
+}
+
+var escapeReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\t", `\t`)
+
+// unescapeQuoted reverses the escaping applied by formatEnvValue inside double
+// quotes. Other backslash sequences are kept literally.
+func unescapeQuoted(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '\\' || s[i+1] == '"') {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+func resolveLineEnding(mode string, doc *field.Document) (string, error) {
+	switch mode {
+	case "", "lf":
+		return "\n", nil
+	case "crlf":
+		return "\r\n", nil
+	case "auto":
+		return detectLineEnding(doc.String()), nil
+	default:
+		return "", fmt.Errorf("unknown line ending %q (want lf, crlf or auto)", mode)
+	}
+}
+
+// detectLineEnding returns the dominant line ending of content; ties and
+// files without line breaks default to LF.
+func detectLineEnding(content string) string {
+	crlf := strings.Count(content, "\r\n")
+	if lf := strings.Count(content, "\n") - crlf; crlf > lf {
+		return "\r\n"
+	}
+
+	return "\n"
+}
+
+func formatSize(n int) string {
+	switch {
+	case n%(1024*1024) == 0:
+		return fmt.Sprintf("%dMB", n/(1024*1024))
+	case n%1024 == 0:
+		return fmt.Sprintf("%dKB", n/1024)
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
+
+// scanRawLines is bufio.ScanLines that keeps the line terminator, so entries
+// can carry their exact source text.
+func scanRawLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF {
+		return len(data), data, nil
 	}
 
-	return env, nil
+	return 0, nil, nil
 }
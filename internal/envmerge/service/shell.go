@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// formatSh is the --format that prints the plan as a shell script instead of
+// writing the destination.
+const formatSh = "sh"
+
+// shellQuote single-quotes v for POSIX shells, where nothing inside single
+// quotes is special but the quote itself: it is written by closing the
+// quotes, adding an escaped quote and reopening them. Line breaks stay
+// literal.
+func shellQuote(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
+
+// printShell writes the keys plan adds or updates as export statements, in
+// sync-block order, for eval "$(envmerge --format sh)". Values are real, not
+// masked, since the script is meant to be run.
+func (s *Service) printShell(plan Plan) error {
+	w := s.stdout
+	if w == nil {
+		w = os.Stdout
+	}
+
+	keys, _ := s.blockKeys(plan.Vars)
+
+	var b strings.Builder
+	for _, k := range keys {
+		if !posixKeyPattern.MatchString(k) {
+			return fmt.Errorf("key %q is not a valid shell variable name", k)
+		}
+		fmt.Fprintf(&b, "export %s=%s\n", k, shellQuote(plan.Vars[k]))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+func Test_shellQuote(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"plain":              "'plain'",
+		"":                   "''",
+		"it's":               `'it'\''s'`,
+		"''":                 `''\'''\'''`,
+		"line1\nline2":       "'line1\nline2'",
+		"$HOME `pwd` \\n \"": "'$HOME `pwd` \\n \"'",
+	}
+	for v, want := range tests {
+		if got := shellQuote(v); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", v, got, want)
+		}
+	}
+}
+
+func Test_Run_formatSh(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, ".env.example")
+	dstPath := filepath.Join(tmpDir, ".env")
+	dst := "KEPT=1\nCHANGED=old\n"
+	for path, content := range map[string]string{
+		srcPath: "KEPT=1\nCHANGED=\"it's new\"\nMULTI=\"line1\nline 2\"\nDOLLAR=$HOME\n",
+		dstPath: dst,
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	s, err := New(config.Config{Src: srcPath, Dst: dstPath, Force: true, Format: formatSh})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var out strings.Builder
+	s.stdout = &out
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	want := "export CHANGED='it'\\''s new'\nexport DOLLAR='$HOME'\nexport MULTI='line1\nline 2'\n"
+	if out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+	if got := mustReadFile(t, dstPath); got != dst {
+		t.Fatalf("destination changed: %q", got)
+	}
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	got, err := exec.Command(sh, "-c", out.String()+`printf '%s|%s|%s' "$CHANGED" "$MULTI" "$DOLLAR"`).Output()
+	if err != nil {
+		t.Fatalf("running the script: %v", err)
+	}
+	if string(got) != "it's new|line1\nline 2|$HOME" {
+		t.Fatalf("evaluated to %q", got)
+	}
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"sort"
+	"strings"
+)
+
+// similarKeys groups the keys of the merged result that collide once case and
+// leading/trailing underscores are ignored, e.g. DB_HOST and _db_host. Groups
+// and the keys inside them are sorted for stable output.
+func similarKeys(sets ...map[string]string) [][]string {
+	byNorm := make(map[string]map[string]struct{})
+	for _, set := range sets {
+		for k := range set {
+			norm := strings.ToUpper(strings.Trim(k, "_"))
+			if byNorm[norm] == nil {
+				byNorm[norm] = make(map[string]struct{})
+			}
+			byNorm[norm][k] = struct{}{}
+		}
+	}
+
+	var groups [][]string
+	for _, keys := range byNorm {
+		if len(keys) < 2 {
+			continue
+		}
+
+		group := make([]string, 0, len(keys))
+		for k := range keys {
+			group = append(group, k)
+		}
+		sort.Strings(group)
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+	return groups
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_similarKeys(t *testing.T) {
+	t.Parallel()
+
+	dst := map[string]string{"_DB_HOST": "a", "PORT": "1", "api_url": "x"}
+	written := map[string]string{"DB_HOST": "b", "API_URL": "y", "NAME": "n"}
+
+	got := similarKeys(dst, written)
+	want := [][]string{
+		{"API_URL", "api_url"},
+		{"DB_HOST", "_DB_HOST"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v; want %#v", got, want)
+	}
+}
+
+func Test_similarKeys_noneWhenDistinct(t *testing.T) {
+	t.Parallel()
+
+	if got := similarKeys(map[string]string{"A": "1", "A_B": "2"}, map[string]string{"AB": "3"}); len(got) != 0 {
+		t.Fatalf("expected no groups, got %#v", got)
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// syncHeaderPattern matches a whole sync block header and captures its time.
+var syncHeaderPattern = regexp.MustCompile(`^# envmerge sync run(?: \(force\))?: (.*)$`)
+
+// parseSince accepts a --since value in the header layout or as a date.
+func parseSince(v string) (time.Time, error) {
+	for _, layout := range []string{time.DateTime, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid --since %q: want %q or %q", v, time.DateOnly, time.DateTime)
+}
+
+// syncedSince returns the keys written by sync blocks dated at or after since,
+// with the time of the latest such block for each. Blocks end at the next
+// header or the insert marker; blocks with an unreadable time are skipped.
+func syncedSince(doc *field.Document, since time.Time) map[string]time.Time {
+	keys := make(map[string]time.Time)
+
+	var (
+		inBlock bool
+		at      time.Time
+	)
+	for _, e := range doc.Entries {
+		trimmed := strings.TrimSpace(e.Raw)
+
+		if e.Kind == field.KindComment && strings.HasPrefix(trimmed, syncHeaderPrefix) {
+			m := syncHeaderPattern.FindStringSubmatch(trimmed)
+			if m == nil {
+				slog.Default().Warn("skipping malformed sync header", "line", e.Line)
+				inBlock = false
+				continue
+			}
+
+			t, err := time.ParseInLocation(time.DateTime, m[1], time.Local)
+			if err != nil {
+				slog.Default().Warn("skipping malformed sync header", "line", e.Line, "error", err)
+				inBlock = false
+				continue
+			}
+
+			inBlock, at = !t.Before(since), t
+			continue
+		}
+		if e.Kind == field.KindComment && trimmed == insertMarker {
+			inBlock = false
+			continue
+		}
+
+		if inBlock && e.Kind == field.KindVar {
+			keys[e.Key] = at
+		}
+	}
+
+	return keys
+}
+
+// ListSince prints the keys synced at or after the --since time, one
+// "KEY<TAB>time" line each, sorted by key. Nothing is written to the
+// destination.
+func (s *Service) ListSince() error {
+	defer func() {
+		if s.dst != nil && s.dst.Dsc != nil {
+			_ = s.dst.Dsc.Close()
+		}
+	}()
+
+	w := s.stdout
+	if w == nil {
+		w = os.Stdout
+	}
+
+	synced := syncedSince(s.dst.Doc, s.since)
+
+	keys := make([]string, 0, len(synced))
+	for k := range synced {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s\t%s\n", k, synced[k].Format(time.DateTime))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
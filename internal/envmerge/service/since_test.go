@@ -0,0 +1,58 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_ListSince(t *testing.T) {
+	t.Parallel()
+
+	in := "OLD=1\n" +
+		"\n# envmerge sync run: 2023-12-31 23:59:59\nA=1\n" +
+		"\n# envmerge sync run: 2024-01-02 10:00:00\nB=2\nC=3\n" +
+		"\n# envmerge sync run: yesterday\nBAD=1\n" +
+		"\n# envmerge sync run (force): 2024-02-01 08:30:00\nA=2\n" +
+		"\n" + insertMarker + "\nLOCAL=1\n"
+
+	doc, err := parseDocument(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+
+	since, err := parseSince("2024-01-01")
+	if err != nil {
+		t.Fatalf("parseSince: %v", err)
+	}
+
+	var out bytes.Buffer
+	s := &Service{since: since, stdout: &out, dst: &field.File{Doc: doc}}
+	if err := s.ListSince(); err != nil {
+		t.Fatalf("ListSince: %v", err)
+	}
+
+	want := "A\t2024-02-01 08:30:00\nB\t2024-01-02 10:00:00\nC\t2024-01-02 10:00:00\n"
+	if out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+}
+
+func Test_parseSince(t *testing.T) {
+	t.Parallel()
+
+	got, err := parseSince("2024-01-02 03:04:05")
+	if err != nil {
+		t.Fatalf("parseSince: %v", err)
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local); !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if _, err := parseSince("last week"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// readTemplate parses the --dst-template layout file. A missing template is
+// not an error: it is logged and nil is returned, so Run falls back to plain
+// appending.
+func readTemplate(dir, file string, p parser) (*field.Document, error) {
+	filePath := resolvePath(dir, file)
+	slog.Default().Info("Reading file", "path", filePath)
+
+	content, err := os.Open(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			slog.Default().Warn("destination template not found, appending instead", "path", filePath)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open %q: %w", filePath, err)
+	}
+	defer content.Close()
+
+	p.file = filePath
+	doc, err := p.document(content)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %q: %w", filePath, err)
+	}
+
+	return doc, nil
+}
+
+// renderTemplate lays out a new destination after the template: its comments,
+// blank lines and key order are kept, and each key planned for writing takes
+// its merged value in place of the template's placeholder. Planned keys the
+// template lacks are appended as a regular sync block.
+func (s *Service) renderTemplate(plan Plan) (string, error) {
+	leftover := make(map[string]string, len(plan.Vars))
+	for k, v := range plan.Vars {
+		leftover[k] = v
+	}
+
+	var b strings.Builder
+	for _, e := range s.template.Entries {
+		v, ok := plan.Vars[e.Key]
+		if e.Kind != field.KindVar || !ok {
+			b.WriteString(e.Raw)
+			continue
+		}
+
+		if s.collapseWhitespace {
+			v = s.collapseValue(e.Key, v)
+		}
+		b.WriteString(s.compactEntry(e, v).Raw)
+		delete(leftover, e.Key)
+	}
+
+	if len(leftover) > 0 && b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+		b.WriteString(s.eol())
+	}
+	if err := s.Apply(Plan{Vars: leftover, Force: plan.Force}, &b); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Run_dstTemplate(t *testing.T) {
+	t.Parallel()
+
+	const layout = "# --- database ---\nDB_HOST=\nexport DB_PORT=5432\n\n# --- other ---\nUNUSED=keep\n"
+
+	cases := []struct {
+		name     string
+		existing string
+		want     string
+	}{
+		{
+			name: "new destination",
+			want: "# --- database ---\nDB_HOST=db\nexport DB_PORT=6432\n\n# --- other ---\nUNUSED=keep\n" +
+				"\n# envmerge sync run: <ts>\nEXTRA=1\n",
+		},
+		{
+			name:     "existing destination ignores template",
+			existing: "DB_HOST=local\n",
+			want:     "DB_HOST=local\n\n# envmerge sync run: <ts>\nDB_PORT=6432\nEXTRA=1\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			dstPath := filepath.Join(tmpDir, ".env")
+			if tc.existing != "" {
+				if err := os.WriteFile(dstPath, []byte(tc.existing), 0o644); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+			}
+			if err := os.WriteFile(filepath.Join(tmpDir, "layout.env"), []byte(layout), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			dst, err := readDstFile(tmpDir, ".env", parser{})
+			if err != nil {
+				t.Fatalf("readDstFile: %v", err)
+			}
+			tmpl, err := readTemplate(tmpDir, "layout.env", parser{})
+			if err != nil {
+				t.Fatalf("readTemplate: %v", err)
+			}
+
+			s := &Service{
+				template: tmpl,
+				src:      map[string]string{"DB_HOST": "db", "DB_PORT": "6432", "EXTRA": "1"},
+				dst:      dst,
+			}
+			if err := s.Run(); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			lines := strings.Split(mustReadFile(t, dstPath), "\n")
+			for i, l := range lines {
+				if strings.HasPrefix(l, "# envmerge sync run: ") {
+					lines[i] = "# envmerge sync run: <ts>"
+				}
+			}
+			if got := strings.Join(lines, "\n"); got != tc.want {
+				t.Fatalf("got  %q\nwant %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_readTemplate_missing(t *testing.T) {
+	t.Parallel()
+
+	doc, err := readTemplate(t.TempDir(), "layout.env", parser{})
+	if err != nil {
+		t.Fatalf("readTemplate: %v", err)
+	}
+	if doc != nil {
+		t.Fatalf("expected nil template, got %+v", doc)
+	}
+}
@@ -0,0 +1,5 @@
+package service
+
+import "syscall"
+
+const ioctlGetTermios = syscall.TCGETS
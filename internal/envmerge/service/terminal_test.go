@@ -0,0 +1,62 @@
+//go:build unix
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_isTerminal_devNull(t *testing.T) {
+	t.Parallel()
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer devNull.Close()
+
+	if isTerminal(devNull) {
+		t.Fatalf("isTerminal(%s) = true", os.DevNull)
+	}
+}
+
+func Test_Run_interactiveDevNullStdin(t *testing.T) {
+	t.Parallel()
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer devNull.Close()
+
+	dstPath := writeTempFile(t, "")
+	dst, err := readDstFile(filepath.Dir(dstPath), filepath.Base(dstPath), parser{})
+	if err != nil {
+		t.Fatalf("readDstFile: %v", err)
+	}
+
+	var out strings.Builder
+	s := &Service{
+		force:               true,
+		interactive:         true,
+		interactiveFallback: interactiveFallbackAll,
+		stdin:               devNull,
+		stdinIsTerminal:     isTerminal(devNull),
+		stdout:              &out,
+		src:                 map[string]string{"A": "1"},
+		dst:                 dst,
+	}
+	if err := s.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if strings.Contains(out.String(), "apply A=1?") {
+		t.Fatalf("prompted without a terminal: %q", out.String())
+	}
+	if got := mustReadFile(t, dstPath); !strings.Contains(got, "A=1") {
+		t.Fatalf("fallback not applied: %q", got)
+	}
+}
@@ -0,0 +1,12 @@
+package service
+
+import (
+	"os"
+	"syscall"
+)
+
+// isTerminal reports whether f is a console rather than a pipe or a file.
+func isTerminal(f *os.File) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(f.Fd()), &mode) == nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// Outcomes of a three-way merge for a key present in both files.
+const (
+	mergeKeep       = iota // unchanged in source, or equal on both sides
+	mergeTakeSource        // changed in source only
+	mergeConflict          // changed on both sides, or no common ancestor
+)
+
+// mergeOutcome compares a key's source and destination values with their
+// common ancestor, the hash the lock file holds from the last sync. Without
+// an ancestor the change cannot be attributed, so differing values conflict.
+func (s *Service) mergeOutcome(key, src, dst string) int {
+	if s.valuesEqual(dst, src) {
+		return mergeKeep
+	}
+
+	ancestor, ok := s.ancestor.Keys[key]
+	if !ok {
+		return mergeConflict
+	}
+
+	srcChanged := s.ancestor.hash(key, src) != ancestor
+	dstChanged := s.ancestor.hash(key, dst) != ancestor
+	switch {
+	case srcChanged && dstChanged:
+		return mergeConflict
+	case srcChanged:
+		return mergeTakeSource
+	default:
+		return mergeKeep
+	}
+}
+
+// determineMerge is determineUpdates for --three-way: besides new keys, it
+// updates only those changed in the source alone. Conflicts keep the
+// destination value.
+func (s *Service) determineMerge() map[string]string {
+	updates := make(map[string]string, len(s.src))
+	for k, v := range s.src {
+		if !s.selected(k) || s.isIgnored(k) {
+			continue
+		}
+		old, ok := s.dst.Data[k]
+		if !ok || (s.mergeOutcome(k, v, old) == mergeTakeSource && s.isManaged(k) && !s.isPlaceholder(v)) {
+			updates[k] = v
+		}
+	}
+
+	return updates
+}
+
+// mergeConflicts returns the sorted keys a three-way merge cannot resolve.
+func (s *Service) mergeConflicts() []string {
+	var keys []string
+	for _, k := range sortedKeys(s.src) {
+		if !s.selected(k) || s.isIgnored(k) {
+			continue
+		}
+		if old, ok := s.dst.Data[k]; ok && s.mergeOutcome(k, s.src[k], old) == mergeConflict {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+// lockMerged records key's ancestor for the next three-way merge: the source
+// value just merged, so a destination edit that was kept still counts as a
+// local change. A conflict keeps its old ancestor until it is resolved, so
+// the next run reports it again instead of taking either side.
+func (s *Service) lockMerged(lock lockFile, key string) {
+	if old, ok := s.dst.Data[key]; ok && s.mergeOutcome(key, s.src[key], old) == mergeConflict {
+		if ancestor, ok := s.ancestor.Keys[key]; ok {
+			lock.Keys[key] = ancestor
+		}
+		return
+	}
+
+	lock.Keys[key] = lock.hash(key, s.src[key])
+}
+
+// logConflicts warns about every key a three-way merge leaves alone.
+func logConflicts(conflicts []string) {
+	for _, k := range conflicts {
+		slog.Default().Warn("key changed in both source and destination since last sync, keeping destination", "key", k)
+	}
+}
+
+// writeConflicts writes the conflicts to --conflicts-out with conflict
+// markers for manual resolution; a stale file from an earlier run is removed
+// once no conflicts are left.
+func (s *Service) writeConflicts(conflicts []string) error {
+	if s.conflictsOut == "" {
+		return nil
+	}
+	if len(conflicts) == 0 {
+		if err := os.Remove(s.conflictsOut); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error removing conflicts file: %w", err)
+		}
+		return nil
+	}
+
+	var b strings.Builder
+	for _, k := range conflicts {
+		fmt.Fprintf(&b, "<<<<<<< %s\n%s=%s\n=======\n%s=%s\n>>>>>>> source\n",
+			s.dst.Path, k, formatEnvValue(s.dst.Data[k]), k, formatEnvValue(s.src[k]))
+	}
+
+	if err := os.WriteFile(s.conflictsOut, []byte(b.String()), 0o600); err != nil {
+		return fmt.Errorf("error writing conflicts file: %w", err)
+	}
+	return nil
+}
+
+// conflictsError fails a run that left conflicts unresolved.
+func conflictsError(conflicts []string) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", field.ErrMergeConflicts, strings.Join(conflicts, ", "))
+}
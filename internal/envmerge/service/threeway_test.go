@@ -0,0 +1,121 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_Run_threeWay(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, ".env.example")
+	dstPath := filepath.Join(tmpDir, ".env")
+	conflictsPath := filepath.Join(tmpDir, ".env.conflicts")
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	run := func() error {
+		t.Helper()
+		s, err := New(config.Config{Src: srcPath, Dst: dstPath, ThreeWay: true, ConflictsOut: conflictsPath})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		return s.Run()
+	}
+	values := func() map[string]string {
+		t.Helper()
+		doc, err := parseDocument(strings.NewReader(mustReadFile(t, dstPath)))
+		if err != nil {
+			t.Fatalf("parseDocument: %v", err)
+		}
+		return doc.Map()
+	}
+
+	// The first run records the common ancestor.
+	write(srcPath, "SRC_ONLY=1\nDST_ONLY=1\nBOTH=1\nSAME=1\n")
+	write(dstPath, "SRC_ONLY=1\nDST_ONLY=1\nBOTH=1\nSAME=1\n")
+	if err := run(); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+
+	write(srcPath, "SRC_ONLY=2\nDST_ONLY=1\nBOTH=2\nSAME=1\nNEW=1\n")
+	write(dstPath, "SRC_ONLY=1\nDST_ONLY=local\nBOTH=local\nSAME=1\n")
+	err := run()
+	if !errors.Is(err, field.ErrMergeConflicts) || !strings.Contains(err.Error(), "BOTH") {
+		t.Fatalf("err = %v, want ErrMergeConflicts for BOTH", err)
+	}
+
+	want := map[string]string{"SRC_ONLY": "2", "DST_ONLY": "local", "BOTH": "local", "SAME": "1", "NEW": "1"}
+	if got := values(); !mapsEqual(got, want) {
+		t.Fatalf("after merge got %v, want %v", got, want)
+	}
+	wantConflicts := "<<<<<<< " + dstPath + "\nBOTH=local\n=======\nBOTH=2\n>>>>>>> source\n"
+	if got := mustReadFile(t, conflictsPath); got != wantConflicts {
+		t.Fatalf("conflicts file = %q, want %q", got, wantConflicts)
+	}
+
+	// Neither the kept local edit nor the conflict is taken over later.
+	if err := run(); !errors.Is(err, field.ErrMergeConflicts) {
+		t.Fatalf("second run err = %v, want ErrMergeConflicts", err)
+	}
+	if got := values(); !mapsEqual(got, want) {
+		t.Fatalf("after second merge got %v, want %v", got, want)
+	}
+
+	// Taking the source value resolves the conflict.
+	write(dstPath, "SRC_ONLY=2\nDST_ONLY=local\nBOTH=2\nSAME=1\nNEW=1\n")
+	if err := run(); err != nil {
+		t.Fatalf("resolved run: %v", err)
+	}
+	if _, err := os.Stat(conflictsPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("conflicts file should be removed, stat err = %v", err)
+	}
+	if got := values()["DST_ONLY"]; got != "local" {
+		t.Fatalf("DST_ONLY = %q, want the local edit kept", got)
+	}
+}
+
+func Test_mergeOutcome(t *testing.T) {
+	t.Parallel()
+
+	ancestor := lockFile{Salt: "00ff"}
+	ancestor.Keys = map[string]string{"K": ancestor.hash("K", "base")}
+	s := &Service{ancestor: ancestor}
+	tests := []struct {
+		key, src, dst string
+		want          int
+	}{
+		{"K", "base", "base", mergeKeep},
+		{"K", "new", "base", mergeTakeSource},
+		{"K", "base", "local", mergeKeep},
+		{"K", "new", "local", mergeConflict},
+		{"K", "same", "same", mergeKeep},
+		{"UNLOCKED", "a", "b", mergeConflict},
+		{"UNLOCKED", "a", "a", mergeKeep},
+	}
+	for _, tt := range tests {
+		if got := s.mergeOutcome(tt.key, tt.src, tt.dst); got != tt.want {
+			t.Errorf("mergeOutcome(%s, %q, %q) = %d, want %d", tt.key, tt.src, tt.dst, got, tt.want)
+		}
+	}
+}
+
+func Test_configure_threeWayForce(t *testing.T) {
+	t.Parallel()
+
+	for _, cfg := range []config.Config{{ThreeWay: true, Force: true}, {ThreeWay: true, ForceKeys: "keys"}, {ThreeWay: true, AddOnly: true}} {
+		if _, err := configure(cfg, t.TempDir()); !errors.Is(err, errThreeWayForce) {
+			t.Errorf("%+v: err = %v, want errThreeWayForce", cfg, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import "log/slog"
+
+// decision explains what a plan does with a source key and why, in the terms
+// of determineNewVars and determineUpdates.
+func (s *Service) decision(key string) string {
+	v := s.src[key]
+	old, inDst := s.dst.Data[key]
+	force := !s.addOnly && (s.force || len(s.forceKeys) > 0)
+
+	switch {
+	case !s.exclude.included(key):
+		return "skipped (filtered by --exclude)"
+	case !s.selected(key):
+		return "skipped (not in --keys-file)"
+	case s.isIgnored(key):
+		return "skipped (ignored by the destination)"
+	case !inDst:
+		return "added (missing in dest)"
+	case s.valuesEqual(old, v):
+		return "unchanged (same value)"
+	case s.threeWay && s.mergeOutcome(key, v, old) == mergeConflict:
+		return "conflict (changed in source and dest)"
+	case s.threeWay && s.mergeOutcome(key, v, old) == mergeKeep:
+		return "skipped (changed in dest only)"
+	case s.addOnly:
+		return "skipped (exists, --add-only)"
+	case !force && !s.threeWay:
+		return "skipped (exists, non-force)"
+	case !s.threeWay && !s.isForced(key):
+		return "skipped (exists, not in --force-keys)"
+	case !s.isManaged(key):
+		return "skipped (not marked managed)"
+	case s.isPlaceholder(v):
+		return "skipped (placeholder-protected)"
+	case s.threeWay:
+		return "updated (changed in source only)"
+	default:
+		return "updated (differs, force)"
+	}
+}
+
+// tracePlan logs the decision for every source key, without values.
+func (s *Service) tracePlan() {
+	for _, k := range sortedKeys(s.src) {
+		slog.Default().Info("trace", "key", k, "decision", s.decision(k))
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_decision(t *testing.T) {
+	t.Parallel()
+
+	exclude, err := parseKeyFilter("SECRET_*")
+	if err != nil {
+		t.Fatalf("parseKeyFilter: %v", err)
+	}
+	doc, err := parseDocument(strings.NewReader("SAME=1\nOLD=old\nHELD=held\nSECRET_KEY=x\n"))
+	if err != nil {
+		t.Fatalf("parseDocument: %v", err)
+	}
+	src := map[string]string{"SAME": "1", "OLD": "new", "HELD": "CHANGE_ME", "SECRET_KEY": "y", "NEW": "1"}
+
+	tests := []struct {
+		name  string
+		force bool
+		want  map[string]string
+	}{
+		{
+			name: "non-force",
+			want: map[string]string{
+				"SAME":       "unchanged (same value)",
+				"OLD":        "skipped (exists, non-force)",
+				"HELD":       "skipped (exists, non-force)",
+				"SECRET_KEY": "skipped (filtered by --exclude)",
+				"NEW":        "added (missing in dest)",
+			},
+		},
+		{
+			name:  "force",
+			force: true,
+			want: map[string]string{
+				"SAME":       "unchanged (same value)",
+				"OLD":        "updated (differs, force)",
+				"HELD":       "skipped (placeholder-protected)",
+				"SECRET_KEY": "skipped (filtered by --exclude)",
+				"NEW":        "added (missing in dest)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Service{
+				force:       tt.force,
+				exclude:     exclude,
+				placeholder: regexp.MustCompile(`^CHANGE_ME$`),
+				src:         src,
+				dst:         &field.File{Data: doc.Map(), Doc: doc},
+			}
+
+			plan := s.Plan().Vars
+			for k, want := range tt.want {
+				got := s.decision(k)
+				if got != want {
+					t.Errorf("decision(%s) = %q, want %q", k, got, want)
+				}
+				// The reason must agree with what the plan does.
+				_, planned := plan[k]
+				if written := strings.HasPrefix(got, "added") || strings.HasPrefix(got, "updated"); written != planned {
+					t.Errorf("decision(%s) = %q, but planned = %v", k, got, planned)
+				}
+			}
+		})
+	}
+}
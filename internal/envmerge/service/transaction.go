@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+)
+
+// backup is a destination as it was before a --transactional batch touched it.
+type backup struct {
+	path    string
+	data    []byte
+	mode    fs.FileMode
+	existed bool
+}
+
+// takeBackup reads path so it can be restored; a missing file is restored by
+// removing it again.
+func takeBackup(path string) (backup, error) {
+	b := backup{path: path}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return b, fmt.Errorf("backup %q: %w", path, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return b, fmt.Errorf("backup %q: %w", path, err)
+	}
+
+	b.data, b.mode, b.existed = data, info.Mode().Perm(), true
+	return b, nil
+}
+
+// restore puts the file back as it was backed up. It reports whether the file
+// had changed, so untouched destinations are not named as rolled back.
+func (b backup) restore() (bool, error) {
+	data, err := os.ReadFile(b.path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if !b.existed {
+			return false, nil
+		}
+	case err != nil:
+		return false, err
+	case b.existed && bytes.Equal(data, b.data):
+		return false, nil
+	}
+
+	if !b.existed {
+		return true, os.Remove(b.path)
+	}
+
+	return true, os.WriteFile(b.path, b.data, b.mode)
+}
+
+// runTransaction syncs every destination or none: each one is backed up
+// before it is synced, and the first failure stops the batch and restores
+// every destination synced so far, the failed one included. Other files a run
+// writes, such as the audit log or manifest, are not rolled back.
+func runTransaction(cfg config.Config, dsts []string) error {
+	var backups []backup
+	for _, dst := range dsts {
+		b, err := takeBackup(dst)
+		if err != nil {
+			return err
+		}
+		backups = append(backups, b)
+
+		c := cfg
+		c.Dst = dst
+
+		s, err := New(c)
+		if err == nil {
+			err = s.Run()
+		}
+		if err != nil {
+			slog.Default().Error("destination failed, rolling back", "path", dst, "error", err)
+			return rollback(backups, fmt.Errorf("%s: %w", dst, err))
+		}
+	}
+
+	slog.Default().Info("batch finished", "destinations", len(dsts), "transactional", true)
+	return nil
+}
+
+// rollback restores backups in reverse order and returns cause together with
+// any restore failures.
+func rollback(backups []backup, cause error) error {
+	errs := []error{cause}
+	var rolledBack []string
+	for i := len(backups) - 1; i >= 0; i-- {
+		b := backups[i]
+		changed, err := b.restore()
+		if err != nil {
+			slog.Default().Error("cannot roll back destination", "path", b.path, "error", err)
+			errs = append(errs, fmt.Errorf("rollback %s: %w", b.path, err))
+			continue
+		}
+		if changed {
+			slog.Default().Warn("rolled back destination", "path", b.path)
+			rolledBack = append(rolledBack, b.path)
+		}
+	}
+
+	slog.Default().Info("batch rolled back", "destinations", len(backups), "rolled_back", rolledBack)
+	return errors.Join(errs...)
+}
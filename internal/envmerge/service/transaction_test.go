@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_RunBatch_transactional(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, ".env.example")
+	first := filepath.Join(tmpDir, "first.env")
+	created := filepath.Join(tmpDir, "created.env")
+	destOnly := filepath.Join(tmpDir, "dest-only.env")
+	last := filepath.Join(tmpDir, "last.env")
+	for path, content := range map[string]string{
+		srcPath:  "A=1\nB=2\n",
+		first:    "B=2\n",
+		destOnly: "LOCAL=1\n",
+		last:     "B=2\n",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	cfg := config.Config{Src: srcPath, FailOnDestOnly: true, Transactional: true}
+	err := RunBatch(cfg, []string{created, first, destOnly, last})
+	if !errors.Is(err, field.ErrDestOnlyKeys) {
+		t.Fatalf("err = %v, want ErrDestOnlyKeys", err)
+	}
+
+	if got := mustReadFile(t, first); got != "B=2\n" {
+		t.Fatalf("first destination should be rolled back, got %q", got)
+	}
+	if _, err := os.Stat(created); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("created destination should be removed again, stat err = %v", err)
+	}
+	if got := mustReadFile(t, last); got != "B=2\n" {
+		t.Fatalf("destination after the failure should be untouched, got %q", got)
+	}
+}
+
+func Test_RunBatch_transactionalSucceeds(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, ".env.example")
+	a := filepath.Join(tmpDir, "a.env")
+	b := filepath.Join(tmpDir, "b.env")
+	if err := os.WriteFile(srcPath, []byte("A=1\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if err := RunBatch(config.Config{Src: srcPath, Transactional: true}, []string{a, b}); err != nil {
+		t.Fatalf("RunBatch: %v", err)
+	}
+	for _, path := range []string{a, b} {
+		if got := mustReadFile(t, path); !strings.Contains(got, "A=1\n") {
+			t.Fatalf("%s = %q, want A=1", path, got)
+		}
+	}
+}
+
+func Test_backup_restore(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("A=1\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	b, err := takeBackup(path)
+	if err != nil {
+		t.Fatalf("takeBackup: %v", err)
+	}
+	if changed, err := b.restore(); err != nil || changed {
+		t.Fatalf("restore of an untouched file = %v, %v; want false, nil", changed, err)
+	}
+
+	if err := os.WriteFile(path, []byte("A=2\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if changed, err := b.restore(); err != nil || !changed {
+		t.Fatalf("restore = %v, %v; want true, nil", changed, err)
+	}
+	if got := mustReadFile(t, path); got != "A=1\n" {
+		t.Fatalf("restored = %q", got)
+	}
+}
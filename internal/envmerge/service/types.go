@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// typeHintPattern matches a trailing "# type:NAME" comment after a value.
+var typeHintPattern = regexp.MustCompile(`(?:^|[ \t]+)#[ \t]*type:[ \t]*([A-Za-z]+)[ \t]*$`)
+
+// cutTypeHint splits a trailing type hint off value.
+func cutTypeHint(value string) (string, string) {
+	m := typeHintPattern.FindStringSubmatchIndex(value)
+	if m == nil {
+		return value, ""
+	}
+
+	return value[:m[0]], strings.ToLower(value[m[2]:m[3]])
+}
+
+// checkType reports why value is not of the hinted type, or nil if it is.
+func checkType(hint, value string) error {
+	switch hint {
+	case "string":
+		return nil
+	case "int":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("not an int")
+		}
+	case "float":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("not a float")
+		}
+	case "bool":
+		if !strings.EqualFold(value, "true") && !strings.EqualFold(value, "false") {
+			return fmt.Errorf("not true or false")
+		}
+	case "url":
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("not an absolute URL")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", hint)
+	}
+
+	return nil
+}
+
+// checkTypes validates the value every hinted key will have after plan is
+// applied, listing each offending key with its value masked if secret.
+func (s *Service) checkTypes(plan Plan) error {
+	var problems []string
+	for k, e := range s.srcEntries {
+		if e.Type == "" {
+			continue
+		}
+
+		value, ok := plan.Vars[k]
+		if !ok {
+			value, ok = s.dst.Data[k]
+		}
+		if !ok {
+			continue
+		}
+
+		if err := checkType(e.Type, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s=%q: %v", k, s.mask(k, value), err))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+	return fmt.Errorf("%w: %s", field.ErrTypeMismatch, strings.Join(problems, "; "))
+}
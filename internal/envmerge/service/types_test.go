@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_parseDocument_typeHints(t *testing.T) {
+	t.Parallel()
+
+	in := "PORT=8080 # type:int\nURL=\"http://x\"  # type:URL\nNOTE=a # just a comment\nPLAIN=1\n"
+
+	doc, err := parser{typeHints: true}.document(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("document: %v", err)
+	}
+
+	vars := doc.Vars()
+	want := map[string][2]string{
+		"PORT":  {"8080", "int"},
+		"URL":   {"http://x", "url"},
+		"NOTE":  {"a # just a comment", ""},
+		"PLAIN": {"1", ""},
+	}
+	for k, w := range want {
+		if vars[k].Value != w[0] || vars[k].Type != w[1] {
+			t.Fatalf("%s: got value %q type %q, want %q %q", k, vars[k].Value, vars[k].Type, w[0], w[1])
+		}
+	}
+
+	plain, err := fileContent(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("fileContent: %v", err)
+	}
+	if plain["PORT"] != "8080 # type:int" {
+		t.Fatalf("hints must stay in the value by default, got %q", plain["PORT"])
+	}
+}
+
+func Test_checkType(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		hint, value string
+		ok          bool
+	}{
+		{"int", "8080", true},
+		{"int", "80a", false},
+		{"float", "1.5", true},
+		{"float", "x", false},
+		{"bool", "TRUE", true},
+		{"bool", "false", true},
+		{"bool", "yes", false},
+		{"url", "https://example.com/db", true},
+		{"url", "example.com", false},
+		{"string", "", true},
+		{"uuid", "x", false},
+	}
+
+	for _, tc := range cases {
+		if err := checkType(tc.hint, tc.value); (err == nil) != tc.ok {
+			t.Fatalf("checkType(%q, %q) = %v, want ok=%v", tc.hint, tc.value, err, tc.ok)
+		}
+	}
+}
+
+func Test_checkTypes(t *testing.T) {
+	t.Parallel()
+
+	src, err := parser{typeHints: true}.document(strings.NewReader("PORT=8080 # type:int\nDEBUG=true # type:bool\nURL=http://x # type:url\n"))
+	if err != nil {
+		t.Fatalf("document: %v", err)
+	}
+
+	s := &Service{
+		srcEntries: src.Vars(),
+		dst:        &field.File{Data: map[string]string{"PORT": "eighty", "URL": "http://local"}},
+	}
+
+	err = s.checkTypes(Plan{Vars: map[string]string{"DEBUG": "maybe"}})
+	if !errors.Is(err, field.ErrTypeMismatch) {
+		t.Fatalf("expected ErrTypeMismatch, got %v", err)
+	}
+	for _, key := range []string{`DEBUG="maybe"`, `PORT="eighty"`} {
+		if !strings.Contains(err.Error(), key) {
+			t.Fatalf("error should name %s: %v", key, err)
+		}
+	}
+	if strings.Contains(err.Error(), "URL") {
+		t.Fatalf("valid URL reported: %v", err)
+	}
+
+	secrets, err := parseKeyFilter(DefaultSecretPattern)
+	if err != nil {
+		t.Fatalf("parseKeyFilter: %v", err)
+	}
+	s.secrets = secrets
+	s.srcEntries["PIN_SECRET"] = field.Entry{Kind: field.KindVar, Key: "PIN_SECRET", Type: "int"}
+	err = s.checkTypes(Plan{Vars: map[string]string{"DEBUG": "false", "PIN_SECRET": "hunter2"}})
+	if err == nil || !strings.Contains(err.Error(), `PIN_SECRET="***"`) || strings.Contains(err.Error(), "hunter2") {
+		t.Fatalf("secret value should be masked: %v", err)
+	}
+	delete(s.srcEntries, "PIN_SECRET")
+
+	s.dst.Data["PORT"] = "80"
+	if err := s.checkTypes(Plan{Vars: map[string]string{"DEBUG": "false"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+// checkUnmodified fails with field.ErrDstModified when the destination's size
+// or modification time differs from when it was read, so a write never
+// clobbers a concurrent change. Without --check-unmodified it does nothing.
+func (s *Service) checkUnmodified() error {
+	if s.dstStat == nil {
+		return nil
+	}
+
+	now, err := statFile(s.dstStat.Path)
+	if err != nil {
+		return fmt.Errorf("error checking destination: %w", err)
+	}
+	if now.Missing != s.dstStat.Missing || now.Size != s.dstStat.Size || now.ModTime != s.dstStat.ModTime {
+		return fmt.Errorf("%w: %s; rerun to merge into its current content", field.ErrDstModified, s.dstStat.Path)
+	}
+
+	return nil
+}
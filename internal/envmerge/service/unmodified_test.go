@@ -0,0 +1,108 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nuntiiscore/envmerge/internal/config"
+	"github.com/nuntiiscore/envmerge/internal/envmerge/field"
+)
+
+func Test_Run_checkUnmodified(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		modify func(t *testing.T, path string)
+	}{
+		{
+			name: "appended",
+			modify: func(t *testing.T, path string) {
+				if err := os.WriteFile(path, []byte("A=1\nLOCAL=1\n"), 0o644); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+			},
+		},
+		{
+			name: "same size touched",
+			modify: func(t *testing.T, path string) {
+				if err := os.WriteFile(path, []byte("A=2\n"), 0o644); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+				later := time.Now().Add(time.Minute)
+				if err := os.Chtimes(path, later, later); err != nil {
+					t.Fatalf("chtimes: %v", err)
+				}
+			},
+		},
+		{
+			name: "removed",
+			modify: func(t *testing.T, path string) {
+				if err := os.Remove(path); err != nil {
+					t.Fatalf("remove: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpDir := t.TempDir()
+			srcPath := filepath.Join(tmpDir, ".env.example")
+			dstPath := filepath.Join(tmpDir, ".env")
+			if err := os.WriteFile(srcPath, []byte("A=1\nB=2\n"), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if err := os.WriteFile(dstPath, []byte("A=1\n"), 0o644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			s, err := New(config.Config{Src: srcPath, Dst: dstPath, CheckUnmodified: true})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			// Another process writes between the read and the write.
+			tt.modify(t, dstPath)
+			before, _ := os.ReadFile(dstPath)
+
+			if err := s.Run(); !errors.Is(err, field.ErrDstModified) {
+				t.Fatalf("err = %v, want ErrDstModified", err)
+			}
+			if after, _ := os.ReadFile(dstPath); string(after) != string(before) {
+				t.Fatalf("destination was written: %q", after)
+			}
+		})
+	}
+}
+
+func Test_Run_checkUnmodifiedUntouched(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	srcPath := filepath.Join(tmpDir, ".env.example")
+	dstPath := filepath.Join(tmpDir, ".env")
+	if err := os.WriteFile(srcPath, []byte("A=1\nB=2\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// A destination that does not exist yet is fine as long as it still
+	// does not, and an existing one as long as nobody touches it.
+	for run := 0; run < 2; run++ {
+		s, err := New(config.Config{Src: srcPath, Dst: dstPath, CheckUnmodified: true})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if err := s.Run(); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+	}
+	if got := mustReadFile(t, dstPath); !mapsEqual(mustParseString(t, got), map[string]string{"A": "1", "B": "2"}) {
+		t.Fatalf("got %q", got)
+	}
+}